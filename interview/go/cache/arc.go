@@ -0,0 +1,122 @@
+package cache
+
+import "container/list"
+
+// ARC 是自适应替换策略：T1 保存只被访问过一次的 key，T2 保存被访问过多次的 key，
+// B1、B2 只记录最近从 T1、T2 淘汰的 key。淘汰后的 key 再次写入时命中 B1 说明
+// 近期性更重要，增大 T1 的目标大小 p，命中 B2 则减小 p，在 LRU 和 LFU 之间自适应
+type ARC[K comparable] struct {
+	capacity int
+	// T1 的目标大小
+	p int
+
+	t1, t2, b1, b2 *list.List
+	items          map[K]*arcNode[K]
+}
+
+type arcNode[K comparable] struct {
+	key  K
+	list *list.List
+	elem *list.Element
+}
+
+// 创建 ARC 淘汰策略，capacity 是预计缓存的条数，用于限制 B1、B2 的长度
+func NewARC[K comparable](capacity int) *ARC[K] {
+	return &ARC[K]{
+		capacity: max(capacity, 1),
+		t1:       list.New(),
+		t2:       list.New(),
+		b1:       list.New(),
+		b2:       list.New(),
+		items:    make(map[K]*arcNode[K]),
+	}
+}
+
+func (a *ARC[K]) Add(key K) {
+	n, ok := a.items[key]
+	if !ok {
+		n = &arcNode[K]{key: key}
+		a.items[key] = n
+		a.moveTo(n, a.t1)
+		a.trimGhosts()
+		return
+	}
+
+	switch n.list {
+	case a.b1:
+		a.p = min(a.capacity, a.p+max(a.b2.Len()/a.b1.Len(), 1))
+	case a.b2:
+		a.p = max(0, a.p-max(a.b1.Len()/a.b2.Len(), 1))
+	}
+	a.moveTo(n, a.t2)
+}
+
+func (a *ARC[K]) Access(key K) {
+	if n, ok := a.items[key]; ok && (n.list == a.t1 || n.list == a.t2) {
+		a.moveTo(n, a.t2)
+	}
+}
+
+// Remove 移除被主动删除或者过期的 key，它们不代表淘汰错误，不记录到 B1、B2
+func (a *ARC[K]) Remove(key K) {
+	if n, ok := a.items[key]; ok && (n.list == a.t1 || n.list == a.t2) {
+		n.list.Remove(n.elem)
+		delete(a.items, key)
+	}
+}
+
+func (a *ARC[K]) Victim() (K, bool) {
+	var from, ghost *list.List
+	switch {
+	case a.t1.Len() > 0 && (a.t1.Len() > a.p || a.t2.Len() == 0):
+		from, ghost = a.t1, a.b1
+	case a.t2.Len() > 0:
+		from, ghost = a.t2, a.b2
+	default:
+		var zero K
+		return zero, false
+	}
+
+	n := from.Back().Value.(*arcNode[K])
+	a.moveTo(n, ghost)
+	a.trimGhosts()
+	return n.key, true
+}
+
+// Reinsert 把 key 从 Victim 放入的 B1、B2 移回原来的 T1、T2，不调整 p
+func (a *ARC[K]) Reinsert(key K) {
+	n, ok := a.items[key]
+	switch {
+	case !ok:
+		// 已经被 trimGhosts 丢弃，作为新的 key 加入
+		a.Add(key)
+	case n.list == a.b1:
+		a.moveTo(n, a.t1)
+	case n.list == a.b2:
+		a.moveTo(n, a.t2)
+	default:
+		a.Access(key)
+	}
+}
+
+func (a *ARC[K]) moveTo(n *arcNode[K], l *list.List) {
+	if n.list != nil {
+		n.list.Remove(n.elem)
+	}
+	n.list = l
+	n.elem = l.PushFront(n)
+}
+
+func (a *ARC[K]) trimGhosts() {
+	for a.t1.Len()+a.b1.Len() > a.capacity && a.b1.Len() > 0 {
+		a.dropGhost(a.b1)
+	}
+	for a.t2.Len()+a.b2.Len() > 2*a.capacity && a.b2.Len() > 0 {
+		a.dropGhost(a.b2)
+	}
+}
+
+func (a *ARC[K]) dropGhost(l *list.List) {
+	n := l.Remove(l.Back()).(*arcNode[K])
+	delete(a.items, n.key)
+}
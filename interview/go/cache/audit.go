@@ -0,0 +1,55 @@
+// The demo is for reacting to cache events
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 模拟用事件订阅记录审计日志：写入、淘汰、删除和过期都会产生事件，
+// 缓冲区很小的订阅在写入突增时丢弃事件，Block 订阅让写入等待
+func SimulateCacheEvents() {
+	cache := NewLocalCache(Config[string, string]{
+		MaxEntries:      3,
+		JanitorInterval: 100 * time.Millisecond,
+		Logger:          logger,
+	})
+	defer cache.Close()
+
+	audit := cache.Subscribe(SubscribeOptions{Buffer: 16})
+	lossy := cache.Subscribe(SubscribeOptions{Buffer: 1, Types: []EventType{EventSet}})
+	blocking := cache.Subscribe(SubscribeOptions{Buffer: 1, Overflow: Block, Types: []EventType{EventSet}})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for e := range audit.C {
+			logger.Info("audit", "event", e.Type.String(), "key", e.Key, "value", e.Value)
+		}
+	}()
+
+	received := 0
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range blocking.C {
+			received++
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i), 200*time.Millisecond)
+	}
+	cache.Delete("key-3")
+	time.Sleep(500 * time.Millisecond)
+
+	audit.Close()
+	blocking.Close()
+	lossy.Close()
+	wg.Wait()
+	logger.Info("subscriptions", "lossy_dropped", lossy.Dropped(), "blocking_received", received, "blocking_dropped", blocking.Dropped())
+}
@@ -0,0 +1,95 @@
+// The demo is for cache avalanche
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 模拟高并发下缓存雪崩的情况，对比不同防护策略下数据库的查询次数和峰值并发
+func SimulateCacheAvalanche() {
+	scenarios := []struct {
+		name string
+		cfg  Config[string, string]
+	}{
+		{"none", Config[string, string]{BreakdownLock: true}},
+		{"ttl jitter", Config[string, string]{BreakdownLock: true, Jitter: 0.3}},
+		{"early refresh", Config[string, string]{BreakdownLock: true, EarlyRefreshBeta: 1}},
+		{"refresh ahead", Config[string, string]{BreakdownLock: true, RefreshAhead: 0.3}},
+		{"jitter + ahead", Config[string, string]{BreakdownLock: true, Jitter: 0.3, RefreshAhead: 0.3}},
+	}
+
+	for _, s := range scenarios {
+		queries, peak, waits := simulateAvalanche(s.cfg)
+		logger.Info("avalanche", "mitigation", s.name, "queries", queries, "peak", peak, "waits", waits)
+	}
+}
+
+// 所有 key 同时写入、同时过期，100 个 goroutine 持续随机访问。
+// 返回预热之后数据库的查询次数、同一时刻的最大并发查询数，以及需要等待数据库的请求数
+func simulateAvalanche(cfg Config[string, string]) (queries, peak, waits int64) {
+	const (
+		keys      = 50
+		workers   = 100
+		expireDur = 500 * time.Millisecond
+		duration  = 1500 * time.Millisecond
+		dbLatency = 20 * time.Millisecond
+	)
+
+	var inflight, maxInflight, queried, waited atomic.Int64
+	query := func(ctx context.Context, key string) (string, error) {
+		queried.Add(1)
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			m := maxInflight.Load()
+			if n <= m || maxInflight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(dbLatency) // 模拟数据库延迟
+		return "Data for " + key, nil
+	}
+
+	cache := NewLocalCache(cfg)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+
+	// 预热，所有 key 在同一时刻写入缓存
+	for i := 0; i < keys; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			cache.GetOrLoad(ctx, key, expireDur, query)
+		}(fmt.Sprintf("key%d", i))
+	}
+	wg.Wait()
+	queried.Store(0)
+	maxInflight.Store(0)
+
+	deadline := time.Now().Add(duration)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				key := fmt.Sprintf("key%d", rand.Intn(keys))
+				start := time.Now()
+				cache.GetOrLoad(ctx, key, expireDur, query)
+				if time.Since(start) >= dbLatency {
+					waited.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return queried.Load(), maxInflight.Load(), waited.Load()
+}
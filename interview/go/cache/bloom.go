@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bits-and-blooms/bloom"
+)
+
+// KeySource 遍历数据源中存在的所有 key，对每个 key 调用 emit
+type KeySource func(ctx context.Context, emit func(key string) error) error
+
+// BloomFilter 是并发安全的布隆过滤器，不支持删除
+type BloomFilter struct {
+	mu sync.RWMutex
+	bf *bloom.BloomFilter
+	// Add 的次数，用于估算当前的误判率
+	n uint
+	// 创建时的预计元素数量和误判率，保存在文件中，参数变化后重新预热
+	expected uint
+	fpRate   float64
+}
+
+// 布隆过滤器文件的格式：
+//
+//	文件头  magic(4) = "LCBF" | version(2) | reserved(2) | created(8) | crc(4)
+//	内容    n(8) | expectedItems(8) | fpRate(8) | 布隆过滤器
+//	校验    crc(4)
+//
+// 和快照使用相同的文件头，最后的 crc 是内容的 CRC-32C
+const (
+	bloomMagic      = "LCBF"
+	bloomParamsSize = 24
+)
+
+// ErrCorruptFilter 表示布隆过滤器文件的格式或校验和不正确
+var ErrCorruptFilter = errors.New("cache: corrupt filter file")
+
+var (
+	_ MembershipFilter = (*BloomFilter)(nil)
+	_ FPREstimator     = (*BloomFilter)(nil)
+)
+
+// 根据预计的元素数量和期望的误判率创建布隆过滤器
+func NewBloomFilter(expectedItems uint, fpRate float64) *BloomFilter {
+	return &BloomFilter{
+		bf:       bloom.NewWithEstimates(max(expectedItems, 1), fpRate),
+		expected: expectedItems,
+		fpRate:   fpRate,
+	}
+}
+
+func (f *BloomFilter) Add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.bf.AddString(key)
+	f.n++
+}
+
+func (f *BloomFilter) Test(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.bf.TestString(key)
+}
+
+// Cap 返回位数组的大小 m
+func (f *BloomFilter) Cap() uint {
+	return f.bf.Cap()
+}
+
+// K 返回哈希函数的个数
+func (f *BloomFilter) K() uint {
+	return f.bf.K()
+}
+
+// Len 返回 Add 的次数，重复加入的 key 会被重复计数，估算的误判率因此偏保守
+func (f *BloomFilter) Len() uint {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.n
+}
+
+// EstimatedFPR 按 (1 - e^(-kn/m))^k 估算当前的误判率
+func (f *BloomFilter) EstimatedFPR() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return bloomFPR(f.bf.Cap(), f.bf.K(), f.n)
+}
+
+func bloomFPR(m, k, n uint) float64 {
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+}
+
+// 把布隆过滤器保存到文件，先写临时文件再重命名，避免重启时读到写了一半的文件
+func SaveBloomFilter(path string, f *BloomFilter) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	var body bytes.Buffer
+	params := make([]byte, bloomParamsSize)
+	f.mu.RLock()
+	binary.BigEndian.PutUint64(params, uint64(f.n))
+	binary.BigEndian.PutUint64(params[8:], uint64(f.expected))
+	binary.BigEndian.PutUint64(params[16:], math.Float64bits(f.fpRate))
+	body.Write(params)
+	_, err = f.bf.WriteTo(&body)
+	f.mu.RUnlock()
+	if err == nil {
+		err = writeHeader(tmp, bloomMagic, time.Now())
+	}
+	if err == nil {
+		_, err = tmp.Write(binary.BigEndian.AppendUint32(body.Bytes(), crc32.Checksum(body.Bytes(), crcTable)))
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 从文件读取 SaveBloomFilter 保存的布隆过滤器，文件不完整或者校验失败时返回 ErrCorruptFilter。
+// 先校验整个文件再解析，损坏的长度字段不会导致按错误的大小分配内存
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize+bloomParamsSize+4 {
+		return nil, fmt.Errorf("%w: %s has %d bytes", ErrCorruptFilter, path, len(data))
+	}
+	if _, err := readHeader(bytes.NewReader(data[:headerSize]), bloomMagic); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorruptFilter, path, err)
+	}
+	body, sum := data[headerSize:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(sum) {
+		return nil, fmt.Errorf("%w: %s checksum mismatch", ErrCorruptFilter, path)
+	}
+
+	f := &BloomFilter{
+		bf:       &bloom.BloomFilter{},
+		n:        uint(binary.BigEndian.Uint64(body)),
+		expected: uint(binary.BigEndian.Uint64(body[8:])),
+		fpRate:   math.Float64frombits(binary.BigEndian.Uint64(body[16:])),
+	}
+	r := bytes.NewReader(body[bloomParamsSize:])
+	if _, err := f.bf.ReadFrom(r); err != nil || r.Len() != 0 {
+		return nil, fmt.Errorf("%w: %s: bad filter data", ErrCorruptFilter, path)
+	}
+	return f, nil
+}
+
+// 把数据源中的 key 批量加入过滤器，返回加入的数量，可以和缓存的读写并发执行
+func WarmFilter(ctx context.Context, f MembershipFilter, src KeySource) (int, error) {
+	n := 0
+	err := src(ctx, func(key string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f.Add(key)
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// 启动时优先从文件恢复布隆过滤器。文件不存在、损坏，或者保存时的预计数量和误判率与参数不同时，
+// 按参数新建并从数据源预热，然后保存到文件
+func LoadOrWarmBloomFilter(ctx context.Context, path string, expectedItems uint, fpRate float64, src KeySource) (*BloomFilter, error) {
+	f, err := LoadBloomFilter(path)
+	if err == nil && f.expected == expectedItems && f.fpRate == fpRate {
+		return f, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrCorruptFilter) {
+		return nil, err
+	}
+
+	f = NewBloomFilter(expectedItems, fpRate)
+	if _, err := WarmFilter(ctx, f, src); err != nil {
+		return nil, err
+	}
+	return f, SaveBloomFilter(path, f)
+}
+
+// 模拟布隆过滤器 + 缓存防止缓存穿透
+func SimulateBloomGuard() {
+	ctx := context.Background()
+	path := filepath.Join(os.TempDir(), "cache-demo.bloom")
+	defer os.Remove(path)
+
+	// 模拟数据库中的一些 key
+	existingKeys := []string{"key1", "key2", "key3"}
+	src := func(ctx context.Context, emit func(key string) error) error {
+		for _, key := range existingKeys {
+			if err := emit(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 第一次启动时按 1000 个元素、0.01 的误判率创建布隆过滤器并预热，保存到文件
+	bf, err := LoadOrWarmBloomFilter(ctx, path, 1000, 0.01, src)
+	if err != nil {
+		logger.Warn("warm bloom filter failed", "error", err)
+		return
+	}
+	logger.Info("bloom filter", "m", bf.Cap(), "k", bf.K())
+
+	// 模拟重启后直接从文件恢复
+	bf, err = LoadOrWarmBloomFilter(ctx, path, 1000, 0.01, src)
+	if err != nil {
+		logger.Warn("reload bloom filter failed", "error", err)
+		return
+	}
+
+	cache := NewLocalCache(Config[string, string]{Filter: bf, Logger: logger})
+	var wg sync.WaitGroup
+
+	// 模拟并发访问缓存或数据库
+	keysToRequest := []string{"key1", "key2", "key100", "key101", "key3"}
+	for _, key := range keysToRequest {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			value, err := cache.GetOrLoad(ctx, key, 5*time.Second, queryFromDB)
+			if errors.Is(err, ErrNotFound) {
+				logger.Info("key does not exist, skipping DB query", "key", key)
+				return
+			}
+			logger.Info("got value", "key", key, "value", value)
+		}(key)
+	}
+
+	wg.Wait()
+}
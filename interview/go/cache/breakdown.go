@@ -0,0 +1,160 @@
+// The demo is for cache breakdown
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 模拟从数据库获取数据
+func queryFromDB(ctx context.Context, key string) (string, error) {
+	logger.Info("querying from DB", "key", key)
+	select {
+	case <-time.After(100 * time.Millisecond): // 模拟数据库延迟
+		return "Data from DB for " + key, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// 模拟热点 key 失效后大量请求同时访问数据库
+func SimulateCacheBreakdown() {
+	clock := NewFakeClock(time.Now())
+	cache := NewLocalCache(Config[string, string]{BreakdownLock: true, Clock: clock, Logger: logger})
+	var wg sync.WaitGroup
+
+	// 设置热点数据，过期时间为 1 秒
+	cache.Set("hotkey", "Hot Data", 1*time.Second)
+	cache.Set("coldkey", "Cold Data", 1*time.Second)
+
+	// 2 秒后缓存已经失效，并发请求触发缓存击穿
+	clock.Add(2 * time.Second)
+
+	// 模拟 10 个 goroutine 并发访问热点数据和冷数据
+	// 同一个 key 只查询一次数据库，hotkey 和 coldkey 的加载并行执行
+	for i := 0; i < 10; i++ {
+		key := "hotkey"
+		if i%5 == 0 {
+			key = "coldkey"
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			value, err := cache.GetOrLoad(context.Background(), key, 5*time.Second, queryFromDB)
+			if err != nil {
+				logger.Warn("load failed", "key", key, "error", err)
+				return
+			}
+			logger.Info("got value", "key", key, "value", value)
+		}()
+	}
+
+	// 调用者可以只等待有限的时间，超时后放弃等待，加载仍会完成并回填缓存
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := cache.GetOrLoad(ctx, "hotkey", 5*time.Second, queryFromDB); err != nil {
+			logger.Warn("load failed", "key", "hotkey", "error", err)
+		}
+	}()
+
+	wg.Wait()
+}
+
+// 模拟热点 key 过期后开启 stale-while-revalidate，请求立即拿到旧值，后台只刷新一次
+func SimulateStaleWhileRevalidate() {
+	clock := NewFakeClock(time.Now())
+	cache := NewLocalCache(Config[string, string]{
+		BreakdownLock: true,
+		StaleTTL:      5 * time.Second, // 过期后最多返回 5 秒内的旧值
+		Clock:         clock,
+		Logger:        logger,
+	})
+	sub := cache.Subscribe(SubscribeOptions{Types: []EventType{EventSet}})
+	defer sub.Close()
+	var wg sync.WaitGroup
+
+	cache.Set("hotkey", "Hot Data", 1*time.Second)
+	<-sub.C
+
+	// 2 秒后缓存已经失效，但仍在 StaleTTL 内
+	clock.Add(2 * time.Second)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			res, err := cache.Fetch(context.Background(), "hotkey", 5*time.Second, queryFromDB)
+			if err != nil {
+				logger.Warn("load failed", "key", "hotkey", "error", err)
+				return
+			}
+			logger.Info("got value", "key", "hotkey", "value", res.Value, "stale", res.Stale, "latency", time.Since(start))
+		}()
+	}
+	wg.Wait()
+
+	// 等待后台刷新写入新值
+	<-sub.C
+	res, _ := cache.Fetch(context.Background(), "hotkey", 5*time.Second, queryFromDB)
+	logger.Info("got value", "key", "hotkey", "value", res.Value, "stale", res.Stale)
+}
+
+// 模拟 3 个副本同时遇到热点 key 失效：进程内的合并只能让每个副本各查一次数据库，
+// 加上基于 L2 的分布式锁后所有副本只查询一次
+func SimulateDistributedBreakdown() {
+	server, err := NewRESPServer("127.0.0.1:0")
+	if err != nil {
+		logger.Warn("start RESP server failed", "error", err)
+		return
+	}
+	defer server.Close()
+
+	for _, locked := range []bool{false, true} {
+		var queries atomic.Int64
+		load := func(ctx context.Context, key string) (string, error) {
+			queries.Add(1)
+			if lease, ok := LeaseFromContext(ctx); ok {
+				logger.Info("loading with lease", "key", key, "token", lease.Token())
+			}
+			return queryFromDB(ctx, key)
+		}
+
+		var wg sync.WaitGroup
+		for replica := 0; replica < 3; replica++ {
+			client := NewRESPClient(server.Addr(), 0)
+			defer client.Close()
+
+			cfg := TieredConfig[string, string]{
+				L2:     client,
+				Codec:  StringCodec{},
+				Prefix: fmt.Sprintf("locked-%t:", locked),
+				Logger: logger,
+			}
+			if locked {
+				cfg.Locker = NewLeaseLocker(client, LeaseConfig{TTL: time.Second, Prefix: "lock:", Logger: logger})
+			}
+			cache := NewTieredCache(cfg)
+
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					cache.GetOrLoad(context.Background(), "hotkey", 5*time.Second, load)
+				}()
+			}
+		}
+		wg.Wait()
+		logger.Info("distributed breakdown", "lock", locked, "queries", queries.Load())
+	}
+}
@@ -1,87 +1,404 @@
-// The demo is for cache breakdown
-
 package cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand"
 	"sync"
 	"time"
 )
 
-type CacheItem struct {
-	Value      string
-	Expiration int64
+// Cache 是统一的泛型缓存接口，击穿、穿透、雪崩等防护策略通过 Config 组合
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Set(key K, value V, ttl time.Duration)
+	Delete(key K)
+	GetOrLoad(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error)
+}
+
+// Loader 在缓存未命中时从数据源加载数据
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+type loadTTLKey struct{}
+
+// limitLoadTTL 在加载函数中调用，让这次加载的结果最多缓存 ttl，
+// 用于数据来自另一层缓存、剩余的有效期比 GetOrLoad 的 ttl 短的情况
+func limitLoadTTL(ctx context.Context, ttl time.Duration) {
+	if limit, ok := ctx.Value(loadTTLKey{}).(*time.Duration); ok {
+		*limit = ttl
+	}
+}
+
+// ErrNotFound 表示数据源中不存在该 key
+var ErrNotFound = errors.New("cache: key not found")
+
+// Result 是 Fetch 的结果，Stale 表示返回的是已经过期、正在后台刷新的旧值
+type Result[V any] struct {
+	Value V
+	Stale bool
+}
+
+type item[V any] struct {
+	value      V
+	expiration int64
+	// 写入时实际使用的 TTL，用于判断是否进入提前刷新的窗口
+	ttl time.Duration
+	// 上一次从数据源加载的耗时，XFetch 据此决定提前刷新的时机
+	delta time.Duration
+	// 估算的内存占用，用于按字节数限制容量
+	size int
+	// 负缓存，表示数据源中不存在该 key
+	negative bool
+}
+
+// result 返回数据的值，负缓存返回 ErrNotFound
+func (it item[V]) result() (V, error) {
+	if it.negative {
+		var zero V
+		return zero, ErrNotFound
+	}
+	return it.value, nil
+}
+
+// LocalCache 是进程内的缓存实现
+type LocalCache[K comparable, V any] struct {
+	cfg  Config[K, V]
+	data map[K]item[V]
+	mu   sync.RWMutex
+	// data 的历史最大长度，用于判断是否需要重建 map
+	peak int
+	// data 中所有数据估算的字节数
+	bytes int64
+
+	// 设置了容量时决定淘汰哪个 key，policy 不是并发安全的，由 policyMu 保护
+	policy   EvictionPolicy[K]
+	policyMu sync.Mutex
+	// 负缓存按写入顺序记录，超过 MaxNegative 时淘汰最早的，由写锁保护
+	negatives *LRU[K]
+
+	janitor  *janitor
+	clock    Clock
+	counters counters
+	// 限流和熔断，分片缓存的所有分片共用一个
+	guard *loadGuard
+	// 开启 HotKeys 时统计访问次数
+	hot *hotKeys[K]
+	// 分发事件给订阅者，分片缓存的所有分片共用一个
+	events *eventHub[K, V]
+	log    *slog.Logger
+
+	// 开启持久化时定期写快照，journal 记录两次快照之间的写入，由写锁保护写入顺序
+	persister *persister[K, V]
+	journal   *appendLog[K, V]
+	// 开启 write-behind 时异步写入后端存储，分片缓存的所有分片共用一个
+	writer *writeBehind[K, V]
+
+	// 按 key 合并数据源加载和后台刷新
+	flight flight[K, V]
+}
+
+var _ Cache[string, string] = (*LocalCache[string, string])(nil)
+
+// 创建新的缓存
+func NewLocalCache[K comparable, V any](cfg Config[K, V]) *LocalCache[K, V] {
+	c := &LocalCache[K, V]{
+		cfg:   cfg,
+		data:  make(map[K]item[V]),
+		log:   cfg.Logger,
+		clock: clockOr(cfg.Clock),
+	}
+	if c.log == nil {
+		c.log = discard
+	}
+	if c.cfg.Rand == nil {
+		c.cfg.Rand = rand.Float64
+	}
+	c.events = newEventHub[K, V](c.clock)
+	c.guard = newLoadGuard(cfg, c.clock, c.log)
+	c.hot = newHotKeys[K](cfg.HotKeys, cfg.HotKeyThreshold)
+	if cfg.NegativeTTL > 0 {
+		c.negatives = NewLRU[K]()
+	}
+	if cfg.MaxEntries > 0 || cfg.MaxBytes > 0 {
+		c.policy = cfg.Policy
+		if c.policy == nil {
+			c.policy = NewLRU[K]()
+		}
+	}
+	if cfg.JanitorInterval > 0 {
+		c.janitor = newJanitor(c.clock, cfg.JanitorInterval, cfg.JanitorSamples, c.sweep, c.compact)
+	}
+	if cfg.Persist.Path != "" {
+		c.persist(cfg.Persist)
+	}
+	if cfg.WriteBehind.Store != nil {
+		c.writer = newWriteBehind(cfg.WriteBehind, c.clock, c.log)
+	}
+	return c
 }
 
-type Cache struct {
-	data   map[string]CacheItem
-	mu     sync.RWMutex
-	dbLock sync.RWMutex
+// 获取缓存数据，过期的数据会被惰性删除
+func (c *LocalCache[K, V]) Get(key K) (V, bool) {
+	c.hot.record(key)
+	it, found := c.lookup(key)
+	if !found || it.negative {
+		c.counters.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+
+	c.counters.hits.Add(1)
+	return it.value, true
 }
 
-func NewCache() *Cache {
-	return &Cache{
-		data: make(map[string]CacheItem),
+// lookup 返回没有过期的数据，包括负缓存
+func (c *LocalCache[K, V]) lookup(key K) (item[V], bool) {
+	it, found := c.peek(key)
+	if !found || c.clock.Now().UnixNano() > it.expiration {
+		return item[V]{}, false
 	}
+
+	if !it.negative {
+		c.touch(key)
+	}
+	return it, true
 }
 
-func (c *Cache) Get(key string) (string, bool) {
+// peek 返回 key 对应的数据，包括已经过期但还在 StaleTTL 宽限期内的数据，
+// 超过宽限期的数据会被惰性删除
+func (c *LocalCache[K, V]) peek(key K) (item[V], bool) {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
+	it, found := c.data[key]
+	c.mu.RUnlock()
 
-	item, found := c.data[key]
 	if !found {
-		return "", false
+		return item[V]{}, false
+	}
+	if c.dead(it, c.clock.Now().UnixNano()) {
+		c.deleteExpired(key, it.expiration)
+		return item[V]{}, false
 	}
+	return it, true
+}
 
-	if time.Now().UnixNano() > item.Expiration {
-		return "", false
+// dead 判断数据是否已经超过宽限期，可以被删除，负缓存没有宽限期。
+// 熔断器没有关闭时数据源不可用，保留所有数据用于兜底
+func (c *LocalCache[K, V]) dead(it item[V], now int64) bool {
+	if c.guard.retaining() {
+		return false
+	}
+	if it.negative {
+		return now > it.expiration
 	}
+	return now > it.expiration+int64(c.cfg.StaleTTL)
+}
 
-	return item.Value, true
+// 设置缓存数据，开启 Jitter 时过期时间会随机抖动，开启 write-behind 时异步写入后端存储
+func (c *LocalCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.set(key, value, ttl, 0, true)
 }
 
-func (c *Cache) Set(key string, value string, duration time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// set 写入内存，writeBack 为 true 时同时放入 write-behind 队列。入队和写入内存在同一把锁内完成，
+// 并发写入同一个 key 时队列中最后的修改和内存一致，事件的顺序也和内存一致
+func (c *LocalCache[K, V]) set(key K, value V, ttl, delta time.Duration, writeBack bool) {
+	ttl = c.jitter(c.hotTTL(key, ttl))
+	it := item[V]{
+		value:      value,
+		expiration: c.clock.Now().Add(ttl).UnixNano(),
+		ttl:        ttl,
+		delta:      delta,
+		size:       c.sizeOf(key, value),
+	}
 
-	c.data[key] = CacheItem{
-		Value:      value,
-		Expiration: time.Now().Add(duration).UnixNano(),
+	c.mu.Lock()
+	evicted := c.store(key, it)
+	c.journal.set(key, it)
+	if writeBack {
+		c.writer.set(key, value)
 	}
+	c.events.enqueue(EventSet, key, value)
+	c.queueEvicted(evicted, EvictCapacity)
+	c.mu.Unlock()
+
+	c.events.flush()
+	c.notifyEvicted(evicted, EvictCapacity)
 }
 
-func queryFromDB(key string) string {
-	fmt.Printf("Querying from DB for key: %s\n", key)
-	time.Sleep(100 * time.Millisecond) // 模拟数据库延迟
-	return "Data from DB for " + key
+// 删除缓存数据，开启 write-behind 时异步从后端存储删除
+func (c *LocalCache[K, V]) Delete(key K) {
+	c.invalidate(key, true)
 }
 
-func (c *Cache) GetOrSet(key string, duration time.Duration, wg *sync.WaitGroup) {
-	defer wg.Done()
+// Invalidate 只删除内存中的数据，不修改后端存储，用于处理其他副本发出的失效消息
+func (c *LocalCache[K, V]) Invalidate(key K) {
+	c.invalidate(key, false)
+}
 
-	retryTimes := 5
-	for i := 0; i < retryTimes; i++ {
-		value, found := c.Get(key)
-		if found {
-			fmt.Printf("Cache hit for [%s:%s]\n", key, value)
-			return
+func (c *LocalCache[K, V]) invalidate(key K, writeBack bool) {
+	c.mu.Lock()
+	it, found := c.data[key]
+	if found {
+		c.remove(key, it)
+		c.journal.delete(key)
+		if !it.negative {
+			c.events.enqueue(EventInvalidate, key, it.value)
 		}
-		fmt.Printf("Cache miss for key: %s\n", key)
 	}
+	// 内存中没有的 key 后端存储中也可能有
+	if writeBack {
+		c.writer.delete(key)
+	}
+	c.mu.Unlock()
 
-	// add lock from caller
-	c.dbLock.Lock()
+	c.events.flush()
+}
 
-	value, found := c.Get(key)
+// 释放读锁到拿到写锁之间，其他 goroutine 可能已经刷新了 key，
+// 只有过期时间仍然是读到的那一个时才删除，避免误删新写入的数据
+func (c *LocalCache[K, V]) deleteExpired(key K, expiration int64) {
+	c.mu.Lock()
+	it, found := c.data[key]
+	found = found && it.expiration == expiration
+	var expired []entry[K, V]
 	if found {
-		fmt.Printf("Cache hit for [%s:%s]\n", key, value)
-		c.dbLock.Unlock()
-		return
+		c.remove(key, it)
+		expired = []entry[K, V]{{key, it.value, it.negative}}
+		c.queueEvicted(expired, EvictExpired)
 	}
+	c.mu.Unlock()
+
+	c.events.flush()
+	c.notifyEvicted(expired, EvictExpired)
+}
+
+// Len 返回缓存中的数据条数，包括还没有被清理的过期数据
+func (c *LocalCache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
 
-	value = queryFromDB(key)
-	c.Set(key, value, duration)
-	fmt.Printf("Updated cache for key: %s\n", key)
-	c.dbLock.Unlock()
+// 获取缓存数据，未命中时通过 load 从数据源加载并回填缓存，
+// ctx 取消或超时后立即返回 ctx.Err()
+func (c *LocalCache[K, V]) GetOrLoad(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error) {
+	res, err := c.Fetch(ctx, key, ttl, load)
+	return res.Value, err
+}
+
+// Fetch 和 GetOrLoad 相同，设置了 StaleTTL 时，过期但还在宽限期内的数据会立即返回，
+// 同时在后台刷新一次，返回结果的 Stale 标记为 true
+func (c *LocalCache[K, V]) Fetch(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (Result[V], error) {
+	c.hot.record(key)
+	if it, found := c.peek(key); found {
+		if it.negative {
+			c.counters.negativeHits.Add(1)
+			c.debug(ctx, "cache negative hit", key)
+			return Result[V]{}, ErrNotFound
+		}
+
+		c.touch(key)
+		if c.clock.Now().UnixNano() <= it.expiration {
+			c.counters.hits.Add(1)
+			c.debug(ctx, "cache hit", key)
+			return Result[V]{Value: c.hit(ctx, key, it, ttl, load)}, nil
+		}
+
+		c.counters.staleHits.Add(1)
+		c.debug(ctx, "cache stale hit", key)
+		c.flight.start(key, c.reload(ctx, key, ttl, load))
+		return Result[V]{Value: it.value, Stale: true}, nil
+	}
+
+	c.counters.misses.Add(1)
+	c.debug(ctx, "cache miss", key)
+	value, err := c.miss(ctx, key, ttl, load)
+	return Result[V]{Value: value}, err
+}
+
+func (c *LocalCache[K, V]) miss(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error) {
+	// 过滤器判断不存在的 key 不访问数据源
+	if !c.mightExist(key) {
+		c.counters.filterRejects.Add(1)
+		c.debug(ctx, "cache filter rejected", key)
+		var zero V
+		return zero, ErrNotFound
+	}
+
+	if !c.cfg.BreakdownLock {
+		return c.load(ctx, key, ttl, load)
+	}
+
+	// 合并后的加载被多个调用者共享，不能因为第一个调用者取消而中断
+	loadCtx := context.WithoutCancel(ctx)
+	return c.flight.do(ctx, key, func() (V, error) {
+		// 再检查一次，上一轮加载可能刚刚完成
+		if it, found := c.lookup(key); found {
+			return it.result()
+		}
+		return c.load(loadCtx, key, ttl, load)
+	})
+}
+
+func (c *LocalCache[K, V]) load(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error) {
+	if c.cfg.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.LoadTimeout)
+		defer cancel()
+	}
+
+	release, err := c.guard.acquire(ctx)
+	if err != nil {
+		c.debug(ctx, "cache load rejected", key, slog.Any("error", err))
+		var zero V
+		return zero, err
+	}
+
+	var limit time.Duration
+	start := c.clock.Now()
+	value, err := load(context.WithValue(ctx, loadTTLKey{}, &limit), key)
+	delta := c.clock.Now().Sub(start)
+	release(err)
+
+	switch {
+	case err == nil:
+		c.counters.observeLoad(delta, nil)
+		c.debug(ctx, "cache load", key, slog.String("outcome", "ok"), slog.Duration("latency", delta))
+	case errors.Is(err, ErrNotFound):
+		c.counters.observeLoad(delta, nil)
+		c.debug(ctx, "cache load", key, slog.String("outcome", "not_found"), slog.Duration("latency", delta))
+	default:
+		c.counters.observeLoad(delta, err)
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache load failed", slog.Any("key", key),
+			slog.String("outcome", "error"), slog.Duration("latency", delta), slog.Any("error", err))
+	}
+
+	if errors.Is(err, ErrNotFound) && c.cfg.NegativeTTL > 0 {
+		// 缓存不存在的结果，后续请求不再穿透到数据源
+		c.setNegative(key, delta)
+		var zero V
+		return zero, err
+	}
+	if err != nil {
+		return value, err
+	}
+
+	if limit > 0 {
+		ttl = min(ttl, limit)
+	}
+	c.set(key, value, ttl, delta, false)
+	return value, nil
+}
+
+func (c *LocalCache[K, V]) mightExist(key K) bool {
+	return c.cfg.Filter == nil || c.cfg.Filter.Test(keyString(key))
+}
+
+func keyString[K comparable](key K) string {
+	if s, ok := any(key).(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
 }
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 读者惰性删除过期数据的同时写者刷新同一个 key，写者刷新后立即读取必须命中
+func TestLazyExpiryKeepsRefreshedKey(t *testing.T) {
+	cache := NewLocalCache(Config[string, int]{})
+	defer cache.Close()
+
+	if lost := raceLazyExpiry(cache, 8, 50000); lost > 0 {
+		t.Fatalf("lost %d refreshed writes", lost)
+	}
+}
+
+func TestSetGetDelete(t *testing.T) {
+	cache := NewLocalCache(Config[string, string]{})
+	defer cache.Close()
+
+	cache.Set("key", "value", time.Hour)
+	if value, found := cache.Get("key"); !found || value != "value" {
+		t.Fatalf("Get = %q, %v, want value, true", value, found)
+	}
+
+	cache.Delete("key")
+	if _, found := cache.Get("key"); found {
+		t.Fatal("Get found deleted key")
+	}
+
+	cache.Set("expired", "value", -time.Second)
+	if _, found := cache.Get("expired"); found {
+		t.Fatal("Get found expired key")
+	}
+}
+
+// 过期后在 StaleTTL 内返回旧值，后台刷新失败时保留旧值，超过 StaleTTL 后才返回加载错误
+func TestStaleWhileRevalidateOnError(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{Clock: clock, StaleTTL: 10 * time.Second})
+	defer cache.Close()
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	release := make(chan error)
+	load := func(ctx context.Context, key string) (int, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			return 1, nil
+		}
+		if err := <-release; err != nil {
+			return 0, err
+		}
+		return int(n), nil
+	}
+	// 等待后台刷新结束，没有正在进行的刷新时立即返回
+	settle := func() {
+		cache.flight.do(ctx, "key", func() (int, error) { return 0, nil })
+	}
+	fetch := func(want Result[int]) {
+		t.Helper()
+		res, err := cache.Fetch(ctx, "key", time.Second, load)
+		if err != nil || res != want {
+			t.Fatalf("Fetch = %+v, %v, want %+v", res, err, want)
+		}
+	}
+
+	fetch(Result[int]{Value: 1})
+	clock.Add(2 * time.Second)
+
+	// 过期后的多次读取都立即返回旧值，后台只刷新一次
+	for i := 0; i < 3; i++ {
+		fetch(Result[int]{Value: 1, Stale: true})
+	}
+	release <- errors.New("database down")
+	settle()
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("loader called %d times, want one background refresh", n)
+	}
+
+	// 刷新失败后继续返回旧值，并再次刷新
+	fetch(Result[int]{Value: 1, Stale: true})
+	release <- nil
+	settle()
+	fetch(Result[int]{Value: 3})
+
+	// 超过 StaleTTL 后同步加载，加载失败时返回错误而不是旧值
+	clock.Add(12 * time.Second)
+	go func() { release <- errors.New("database down") }()
+	if res, err := cache.Fetch(ctx, "key", time.Second, load); err == nil || res.Stale {
+		t.Fatalf("Fetch = %+v, %v after StaleTTL, want load error", res, err)
+	}
+	if stats := cache.Stats(); stats.StaleHits != 4 || stats.LoadErrors != 2 {
+		t.Fatalf("StaleHits = %d, LoadErrors = %d, want 4 and 2", stats.StaleHits, stats.LoadErrors)
+	}
+}
+
+// 数据源返回 ErrNotFound 后在 NegativeTTL 内不再访问数据源，负缓存不产生事件，也不占用 MaxEntries
+func TestNegativeCache(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{
+		Clock:       clock,
+		NegativeTTL: 5 * time.Second,
+		MaxNegative: 2,
+		MaxEntries:  1,
+	})
+	defer cache.Close()
+	ctx := context.Background()
+	sub := cache.Subscribe(SubscribeOptions{})
+	defer sub.Close()
+
+	loads := map[string]int{}
+	load := func(ctx context.Context, key string) (int, error) {
+		loads[key]++
+		return 0, ErrNotFound
+	}
+	fetch := func(key string) {
+		t.Helper()
+		if _, err := cache.GetOrLoad(ctx, key, time.Hour, load); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetOrLoad(%s) error = %v, want ErrNotFound", key, err)
+		}
+	}
+
+	cache.Set("real", 1, time.Hour)
+	recv(sub)
+
+	fetch("missing")
+	fetch("missing")
+	if loads["missing"] != 1 {
+		t.Fatalf("loaded missing key %d times within NegativeTTL, want 1", loads["missing"])
+	}
+	if _, found := cache.Get("missing"); found {
+		t.Fatal("Get found a negative entry")
+	}
+	if stats := cache.Stats(); stats.NegativeHits != 1 {
+		t.Fatalf("NegativeHits = %d, want 1", stats.NegativeHits)
+	}
+
+	// 负缓存超过 MaxNegative 时淘汰最早写入的负缓存，不影响正常数据
+	fetch("a")
+	fetch("b")
+	if _, found := cache.Get("real"); !found {
+		t.Fatal("negative entries evicted a real entry")
+	}
+	fetch("missing")
+	if loads["missing"] != 2 {
+		t.Fatalf("loaded missing key %d times after it was pushed out, want 2", loads["missing"])
+	}
+
+	// 过期后重新访问数据源
+	clock.Add(5*time.Second + time.Nanosecond)
+	fetch("b")
+	if loads["b"] != 2 {
+		t.Fatalf("loaded b %d times after NegativeTTL, want 2", loads["b"])
+	}
+
+	if events := recv(sub); len(events) != 0 {
+		t.Fatalf("negative entries published %v", events)
+	}
+}
@@ -0,0 +1,58 @@
+// The demo is for bounded capacity and eviction policies
+
+package cache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// 模拟热点访问中夹杂顺序扫描，对比不同淘汰策略的命中率
+func SimulateEviction() {
+	const (
+		capacity = 100
+		requests = 100000
+	)
+
+	policies := []struct {
+		name   string
+		policy EvictionPolicy[int]
+	}{
+		{"LRU", NewLRU[int]()},
+		{"LFU", NewLFU[int]()},
+		{"ARC", NewARC[int](capacity)},
+	}
+
+	for _, p := range policies {
+		var evicted int
+		cache := NewLocalCache(Config[int, int]{
+			MaxEntries: capacity,
+			Policy:     p.policy,
+			OnEvict: func(key, value int, reason EvictReason) {
+				evicted++
+			},
+		})
+
+		r := rand.New(rand.NewSource(1))
+		zipf := rand.NewZipf(r, 1.1, 1, 10000)
+		scan := 0
+
+		hits := 0
+		for i := 0; i < requests; i++ {
+			key := int(zipf.Uint64())
+			// 每 4 次请求里有一次顺序扫描冷数据
+			if i%4 == 0 {
+				key = 10000 + scan
+				scan++
+			}
+
+			if _, found := cache.Get(key); found {
+				hits++
+				continue
+			}
+			cache.Set(key, key, time.Hour)
+		}
+
+		logger.Info("eviction", "policy", p.name, "hit_ratio", float64(hits)/requests, "evicted", evicted)
+	}
+}
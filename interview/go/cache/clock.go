@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 是缓存使用的时钟，过期时间、后台清理和定期任务都从它读取时间，
+// 测试时用 FakeClock 手动推进时间，不需要真的等待
+type Clock interface {
+	Now() time.Time
+	// NewTicker 和 time.NewTicker 相同
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 是 Clock 创建的定时器
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+// systemClock 使用系统时间，是默认的时钟
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+// sleep 在 clock 上等待 d，stop 先关闭时返回 false
+func sleep(clock Clock, d time.Duration, stop <-chan struct{}) bool {
+	ticker := clock.NewTicker(d)
+	defer ticker.Stop()
+
+	select {
+	case <-ticker.Chan():
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// clockOr 在 clock 为空时返回系统时钟
+func clockOr(clock Clock) Clock {
+	if clock == nil {
+		return systemClock{}
+	}
+	return clock
+}
+
+// FakeClock 是手动推进的时钟，只有调用 Add 或者 Set 时时间才会变化。
+// 和 time.Ticker 一样，订阅者来不及接收时 Ticker 会丢弃多余的触发
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// 创建从 start 开始的手动时钟
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Add 把时间向前推进 d，并触发到期的 Ticker
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.advance(c.now.Add(d))
+}
+
+// Set 把时间设置为 t，t 早于当前时间时不会触发 Ticker
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.advance(t)
+}
+
+// advance 修改时间并触发到期的 Ticker，调用方持有锁
+func (c *FakeClock) advance(t time.Time) {
+	c.now = t
+
+	active := c.tickers[:0]
+	for _, tk := range c.tickers {
+		if tk.stopped {
+			continue
+		}
+		active = append(active, tk)
+		if !tk.next.After(t) {
+			select {
+			case tk.c <- t:
+			default:
+			}
+			// 一次推进多个周期时只触发一次
+			tk.next = tk.next.Add((t.Sub(tk.next)/tk.period + 1) * tk.period)
+		}
+	}
+	c.tickers = active
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("cache: non-positive interval for NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tk := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, tk)
+	return tk
+}
+
+type fakeTicker struct {
+	clock   *FakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = true
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 等待 key 的某类事件，后台刷新和后台清理在其他 goroutine 中完成
+func waitEvent[K comparable, V any](t *testing.T, sub *Subscription[K, V], typ EventType, key K) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-sub.C:
+			if e.Type == typ && e.Key == key {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %v event for %v", typ, key)
+		}
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	ticker := clock.NewTicker(time.Second)
+
+	ticked := func() bool {
+		select {
+		case <-ticker.Chan():
+			return true
+		default:
+			return false
+		}
+	}
+
+	clock.Add(500 * time.Millisecond)
+	if ticked() {
+		t.Fatal("ticked before the period")
+	}
+	clock.Add(500 * time.Millisecond)
+	if !ticked() {
+		t.Fatal("did not tick after the period")
+	}
+	clock.Add(5 * time.Second)
+	if !ticked() || ticked() {
+		t.Fatal("want exactly one tick after several periods")
+	}
+
+	ticker.Stop()
+	clock.Add(time.Second)
+	if ticked() {
+		t.Fatal("ticked after Stop")
+	}
+}
+
+func TestExpiryWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{Clock: clock, StaleTTL: 5 * time.Second})
+	defer cache.Close()
+
+	cache.Set("key", 1, 10*time.Second)
+	clock.Add(10 * time.Second)
+	if _, found := cache.Get("key"); !found {
+		t.Fatal("key expired before its TTL")
+	}
+
+	clock.Add(time.Second)
+	if _, found := cache.Get("key"); found {
+		t.Fatal("Get found expired key")
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("Len = %d within StaleTTL, want 1", cache.Len())
+	}
+
+	clock.Add(5 * time.Second)
+	cache.Get("key")
+	if cache.Len() != 0 {
+		t.Fatalf("Len = %d after StaleTTL, want 0", cache.Len())
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		rand    float64
+		expires time.Duration
+	}{
+		{0, 5 * time.Second},
+		{0.5, 10 * time.Second},
+		{0.75, 12500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		clock := NewFakeClock(epoch)
+		cache := NewLocalCache(Config[string, int]{
+			Clock:  clock,
+			Jitter: 0.5,
+			Rand:   func() float64 { return tt.rand },
+		})
+
+		cache.Set("key", 1, 10*time.Second)
+		clock.Add(tt.expires)
+		if _, found := cache.Get("key"); !found {
+			t.Errorf("rand %v: key expired before %v", tt.rand, tt.expires)
+		}
+		clock.Add(time.Millisecond)
+		if _, found := cache.Get("key"); found {
+			t.Errorf("rand %v: key still found after %v", tt.rand, tt.expires)
+		}
+		cache.Close()
+	}
+}
+
+func TestEarlyRefresh(t *testing.T) {
+	var r atomic.Value
+	r.Store(0.0)
+
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{
+		Clock:            clock,
+		EarlyRefreshBeta: 1,
+		Rand:             func() float64 { return r.Load().(float64) },
+	})
+	defer cache.Close()
+
+	var loads atomic.Int32
+	load := func(ctx context.Context, key string) (int, error) {
+		// 加载耗时 1 秒，XFetch 以它作为 delta
+		clock.Add(time.Second)
+		return int(loads.Add(1)), nil
+	}
+
+	ctx := context.Background()
+	cache.GetOrLoad(ctx, "key", 10*time.Second, load)
+	clock.Add(6 * time.Second)
+
+	// -ln(1-0) = 0，没有提前量
+	if value, _ := cache.GetOrLoad(ctx, "key", 10*time.Second, load); value != 1 {
+		t.Fatalf("GetOrLoad = %d, want the cached value without early refresh", value)
+	}
+
+	// 提前量 -ln(0.001) 约为 6.9 秒，超过了剩余的 4 秒
+	r.Store(0.999)
+	if value, _ := cache.GetOrLoad(ctx, "key", 10*time.Second, load); value != 2 {
+		t.Fatalf("GetOrLoad = %d, want an early refreshed value", value)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{Clock: clock, RefreshAhead: 0.2})
+	defer cache.Close()
+	sub := cache.Subscribe(SubscribeOptions{Types: []EventType{EventSet}})
+	defer sub.Close()
+
+	var loads atomic.Int32
+	load := func(ctx context.Context, key string) (int, error) {
+		return int(loads.Add(1)), nil
+	}
+
+	ctx := context.Background()
+	cache.GetOrLoad(ctx, "key", 10*time.Second, load)
+	waitEvent(t, sub, EventSet, "key")
+
+	clock.Add(7 * time.Second)
+	cache.GetOrLoad(ctx, "key", 10*time.Second, load)
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loaded %d times before the refresh-ahead window, want 1", n)
+	}
+
+	// 剩余 1.5 秒，少于 TTL 的 20%，返回旧值并在后台刷新
+	clock.Add(1500 * time.Millisecond)
+	if value, _ := cache.GetOrLoad(ctx, "key", 10*time.Second, load); value != 1 {
+		t.Fatalf("GetOrLoad = %d, want the current value while refreshing", value)
+	}
+	waitEvent(t, sub, EventSet, "key")
+	if value, _ := cache.Get("key"); value != 2 {
+		t.Fatalf("Get = %d after refresh-ahead, want 2", value)
+	}
+
+	clock.Add(9 * time.Second)
+	if _, found := cache.Get("key"); !found {
+		t.Fatal("refreshed key expired with the old expiration")
+	}
+}
+
+func TestJanitorReclaim(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{Clock: clock, JanitorInterval: time.Second})
+	defer cache.Close()
+	sub := cache.Subscribe(SubscribeOptions{Types: []EventType{EventExpire}})
+	defer sub.Close()
+
+	cache.Set("expired", 1, time.Second)
+	cache.Set("live", 2, time.Hour)
+
+	clock.Add(2 * time.Second)
+	waitEvent(t, sub, EventExpire, "expired")
+	cache.Close()
+
+	stats := cache.JanitorStats()
+	if stats.Runs != 1 || stats.Reclaimed != 1 {
+		t.Fatalf("JanitorStats = %+v, want one run reclaiming one key", stats)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("Len = %d, want only the live key", cache.Len())
+	}
+}
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Codec 把缓存的值编码成字节，用于远程缓存、快照等只能保存字节的存储
+type Codec[V any] interface {
+	Encode(value V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// StringCodec 直接使用字符串的字节
+type StringCodec struct{}
+
+var _ Codec[string] = StringCodec{}
+
+func (StringCodec) Encode(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// BytesCodec 直接使用 []byte，解码时复制一份，避免引用调用方的缓冲区
+type BytesCodec struct{}
+
+var _ Codec[[]byte] = BytesCodec{}
+
+func (BytesCodec) Encode(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return bytes.Clone(data), nil
+}
+
+// GobCodec 使用 encoding/gob，支持大部分 Go 类型，但每个值都带有类型描述，编码结果较大
+type GobCodec[V any] struct{}
+
+func (GobCodec[V]) Encode(value V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// JSONCodec 使用 encoding/json，只编码导出的字段，便于其他语言的服务读取
+type JSONCodec[V any] struct{}
+
+func (JSONCodec[V]) Encode(value V) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec[V]) Decode(data []byte) (V, error) {
+	var value V
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// BinaryCodec 按 protobuf 的方式编码基本类型：整数使用 varint，有符号整数先做 zigzag，
+// 浮点数使用小端定长，字符串和 []byte 直接使用字节。其他类型需要实现
+// encoding.BinaryMarshaler 和 encoding.BinaryUnmarshaler，或者 protobuf 生成的 Marshal 和 Unmarshal
+type BinaryCodec[V any] struct{}
+
+type protoMessage interface {
+	Marshal() ([]byte, error)
+}
+
+type protoUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+func (BinaryCodec[V]) Encode(value V) ([]byte, error) {
+	switch v := any(value).(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case bool:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case int:
+		return binary.AppendVarint(nil, int64(v)), nil
+	case int32:
+		return binary.AppendVarint(nil, int64(v)), nil
+	case int64:
+		return binary.AppendVarint(nil, v), nil
+	case uint:
+		return binary.AppendUvarint(nil, uint64(v)), nil
+	case uint32:
+		return binary.AppendUvarint(nil, uint64(v)), nil
+	case uint64:
+		return binary.AppendUvarint(nil, v), nil
+	case float32:
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	case protoMessage:
+		return v.Marshal()
+	}
+	return nil, fmt.Errorf("cache: binary codec does not support %T", value)
+}
+
+func (BinaryCodec[V]) Decode(data []byte) (V, error) {
+	var value V
+	var err error
+
+	switch p := any(&value).(type) {
+	case *string:
+		*p = string(data)
+	case *[]byte:
+		*p = bytes.Clone(data)
+	case *bool:
+		if len(data) != 1 || data[0] > 1 {
+			return value, fmt.Errorf("cache: invalid bool %x", data)
+		}
+		*p = data[0] == 1
+	case *int:
+		var n int64
+		n, err = decodeVarint(data, math.MinInt, math.MaxInt)
+		*p = int(n)
+	case *int32:
+		var n int64
+		n, err = decodeVarint(data, math.MinInt32, math.MaxInt32)
+		*p = int32(n)
+	case *int64:
+		*p, err = decodeVarint(data, math.MinInt64, math.MaxInt64)
+	case *uint:
+		var n uint64
+		n, err = decodeUvarint(data, math.MaxUint)
+		*p = uint(n)
+	case *uint32:
+		var n uint64
+		n, err = decodeUvarint(data, math.MaxUint32)
+		*p = uint32(n)
+	case *uint64:
+		*p, err = decodeUvarint(data, math.MaxUint64)
+	case *float32:
+		if len(data) != 4 {
+			return value, fmt.Errorf("cache: invalid float32 of %d bytes", len(data))
+		}
+		*p = math.Float32frombits(binary.LittleEndian.Uint32(data))
+	case *float64:
+		if len(data) != 8 {
+			return value, fmt.Errorf("cache: invalid float64 of %d bytes", len(data))
+		}
+		*p = math.Float64frombits(binary.LittleEndian.Uint64(data))
+	case encoding.BinaryUnmarshaler:
+		err = p.UnmarshalBinary(data)
+	case protoUnmarshaler:
+		err = p.Unmarshal(data)
+	default:
+		// protobuf 生成的消息通常以指针类型使用，先分配指向的值
+		t := reflect.TypeOf(value)
+		if t == nil || t.Kind() != reflect.Pointer {
+			return value, fmt.Errorf("cache: binary codec does not support %T", value)
+		}
+		ptr := reflect.New(t.Elem()).Interface()
+		switch u := ptr.(type) {
+		case encoding.BinaryUnmarshaler:
+			err = u.UnmarshalBinary(data)
+		case protoUnmarshaler:
+			err = u.Unmarshal(data)
+		default:
+			return value, fmt.Errorf("cache: binary codec does not support %T", value)
+		}
+		value = ptr.(V)
+	}
+	return value, err
+}
+
+func decodeVarint(data []byte, lo, hi int64) (int64, error) {
+	// size 为 0 表示数据为空或者不完整，小于 0 表示溢出
+	n, size := binary.Varint(data)
+	if size <= 0 || size != len(data) || n < lo || n > hi {
+		return 0, fmt.Errorf("cache: invalid varint %x", data)
+	}
+	return n, nil
+}
+
+func decodeUvarint(data []byte, hi uint64) (uint64, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || size != len(data) || n > hi {
+		return 0, fmt.Errorf("cache: invalid uvarint %x", data)
+	}
+	return n, nil
+}
+
+// DefaultCodec 返回 T 的默认编码：string 和 []byte 直接使用字节，其他类型使用 gob
+func DefaultCodec[T any]() Codec[T] {
+	if c, ok := any(StringCodec{}).(Codec[T]); ok {
+		return c
+	}
+	if c, ok := any(BytesCodec{}).(Codec[T]); ok {
+		return c
+	}
+	return GobCodec[T]{}
+}
+
+// CodecSizer 返回按编码后的长度计算数据大小的 Sizer，用于 MaxBytes 按实际写入 L2
+// 或者快照的字节数限制容量。每次写入都会编码一次，编码失败时按 estimateSize 估算
+func CodecSizer[K comparable, V any](codec Codec[V]) func(key K, value V) int {
+	return func(key K, value V) int {
+		data, err := codec.Encode(value)
+		if err != nil {
+			return estimateSize(key) + estimateSize(value)
+		}
+		return estimateSize(key) + len(data)
+	}
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type codecItem struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]int
+	At    time.Time
+	Child *codecItem
+}
+
+// codecPoint 像 protobuf 生成的消息一样以指针类型实现编解码
+type codecPoint struct {
+	X, Y int32
+}
+
+func (p *codecPoint) MarshalBinary() ([]byte, error) {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(p.X))
+	return binary.LittleEndian.AppendUint32(data, uint32(p.Y)), nil
+}
+
+func (p *codecPoint) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("codecPoint: invalid length")
+	}
+	p.X = int32(binary.LittleEndian.Uint32(data))
+	p.Y = int32(binary.LittleEndian.Uint32(data[4:]))
+	return nil
+}
+
+var nestedItem = codecItem{
+	Name:  "a",
+	Tags:  []string{"x", "y"},
+	Attrs: map[string]int{"n": 1},
+	At:    time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+	Child: &codecItem{Name: "b", Tags: []string{"z"}},
+}
+
+// roundTrip 检查 value 编码再解码后不变
+func roundTrip[V any](codec Codec[V], value V) func(t *testing.T) {
+	return func(t *testing.T) {
+		data, err := codec.Encode(value)
+		if err != nil {
+			t.Fatalf("Encode(%v) error: %v", value, err)
+		}
+		got, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode(%x) error: %v", data, err)
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Fatalf("Decode(Encode(%v)) = %v", value, got)
+		}
+	}
+}
+
+// truncated 检查 value 的编码去掉最后 n 个字节后解码返回错误
+func truncated[V any](codec Codec[V], value V, n int) func(t *testing.T) {
+	return func(t *testing.T) {
+		data, err := codec.Encode(value)
+		if err != nil {
+			t.Fatalf("Encode(%v) error: %v", value, err)
+		}
+		data = data[:len(data)-n]
+		if got, err := codec.Decode(data); err == nil {
+			t.Fatalf("Decode(%x) = %v, want error", data, got)
+		}
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"zero int", roundTrip(GobCodec[int]{}, 0)},
+		{"zero string", roundTrip(GobCodec[string]{}, "")},
+		{"zero struct", roundTrip(GobCodec[codecItem]{}, codecItem{})},
+		{"int", roundTrip(GobCodec[int]{}, -300)},
+		{"nested", roundTrip(GobCodec[codecItem]{}, nestedItem)},
+		{"slice of structs", roundTrip(GobCodec[[]codecItem]{}, []codecItem{nestedItem, {Name: "c"}})},
+		{"map", roundTrip(GobCodec[map[string][]int]{}, map[string][]int{"a": {1, 2}})},
+		{"truncated nested", truncated(GobCodec[codecItem]{}, nestedItem, 1)},
+		{"truncated int", truncated(GobCodec[int]{}, 1<<40, 1)},
+		{"empty", truncated(GobCodec[int]{}, 1, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"zero int", roundTrip(JSONCodec[int]{}, 0)},
+		{"zero string", roundTrip(JSONCodec[string]{}, "")},
+		{"zero struct", roundTrip(JSONCodec[codecItem]{}, codecItem{})},
+		{"zero pointer", roundTrip(JSONCodec[*codecItem]{}, nil)},
+		{"nested", roundTrip(JSONCodec[codecItem]{}, nestedItem)},
+		{"pointer", roundTrip(JSONCodec[*codecItem]{}, &nestedItem)},
+		{"map", roundTrip(JSONCodec[map[string][]int]{}, map[string][]int{"a": {1, 2}})},
+		{"truncated nested", truncated(JSONCodec[codecItem]{}, nestedItem, 1)},
+		{"truncated string", truncated(JSONCodec[string]{}, "abc", 1)},
+		{"empty", truncated(JSONCodec[int]{}, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestBinaryCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"zero int", roundTrip(BinaryCodec[int]{}, 0)},
+		{"zero uint64", roundTrip(BinaryCodec[uint64]{}, 0)},
+		{"zero float64", roundTrip(BinaryCodec[float64]{}, 0)},
+		{"zero bool", roundTrip(BinaryCodec[bool]{}, false)},
+		{"zero string", roundTrip(BinaryCodec[string]{}, "")},
+		{"zero time", roundTrip(BinaryCodec[time.Time]{}, time.Time{})},
+		{"min int64", roundTrip(BinaryCodec[int64]{}, math.MinInt64)},
+		{"max uint64", roundTrip(BinaryCodec[uint64]{}, math.MaxUint64)},
+		{"negative int32", roundTrip(BinaryCodec[int32]{}, -300)},
+		{"float32", roundTrip(BinaryCodec[float32]{}, 1.5)},
+		{"bytes", roundTrip(BinaryCodec[[]byte]{}, []byte{0, 1, 2})},
+		{"time", roundTrip(BinaryCodec[time.Time]{}, nestedItem.At)},
+		{"pointer message", roundTrip(BinaryCodec[*codecPoint]{}, &codecPoint{X: -1, Y: 2})},
+		{"truncated varint", truncated(BinaryCodec[int]{}, 300, 1)},
+		{"truncated uvarint", truncated(BinaryCodec[uint64]{}, math.MaxUint64, 1)},
+		{"truncated float64", truncated(BinaryCodec[float64]{}, 1.5, 1)},
+		{"truncated float32", truncated(BinaryCodec[float32]{}, 1.5, 1)},
+		{"empty bool", truncated(BinaryCodec[bool]{}, true, 1)},
+		{"empty int", truncated(BinaryCodec[int]{}, 0, 1)},
+		{"truncated time", truncated(BinaryCodec[time.Time]{}, nestedItem.At, 1)},
+		{"truncated pointer message", truncated(BinaryCodec[*codecPoint]{}, &codecPoint{X: 1}, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestBinaryCodecRange(t *testing.T) {
+	// int64 的编码超出 int32 的范围时不能截断成 int32
+	data, _ := BinaryCodec[int64]{}.Encode(math.MaxInt32 + 1)
+	if got, err := (BinaryCodec[int32]{}).Decode(data); err == nil {
+		t.Fatalf("Decode(%x) as int32 = %d, want error", data, got)
+	}
+	data, _ = BinaryCodec[uint64]{}.Encode(math.MaxUint32 + 1)
+	if got, err := (BinaryCodec[uint32]{}).Decode(data); err == nil {
+		t.Fatalf("Decode(%x) as uint32 = %d, want error", data, got)
+	}
+	if _, err := (BinaryCodec[bool]{}).Decode([]byte{2}); err == nil {
+		t.Fatal("Decode(02) as bool succeeded")
+	}
+	if _, err := (BinaryCodec[codecItem]{}).Encode(nestedItem); err == nil {
+		t.Fatal("Encode of a struct without MarshalBinary succeeded")
+	}
+	if _, err := (BinaryCodec[codecItem]{}).Decode(nil); err == nil {
+		t.Fatal("Decode of a struct without UnmarshalBinary succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+// The demo is for cross-instance invalidation
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// 模拟两个副本通过失效消息保持一致：副本 a 更新 key 后，副本 b 删除 L1 中的旧值并从 L2 读取新值，
+// 不需要等待 L1TTL。依次使用进程内、TCP 和 UDP 组播三种传输
+func SimulateInvalidationBus() {
+	server, err := NewRESPServer("127.0.0.1:0")
+	if err != nil {
+		logger.Warn("start RESP server failed", "error", err)
+		return
+	}
+	defer server.Close()
+
+	client := NewRESPClient(server.Addr(), 0)
+	defer client.Close()
+
+	hub, err := NewInvalidationHub("127.0.0.1:0")
+	if err != nil {
+		logger.Warn("start invalidation hub failed", "error", err)
+		return
+	}
+	defer hub.Close()
+
+	broker := NewChannelBroker()
+	transports := []struct {
+		name string
+		new  func() (InvalidationTransport, error)
+	}{
+		{"channel", func() (InvalidationTransport, error) { return broker.Transport(), nil }},
+		{"tcp", func() (InvalidationTransport, error) { return NewTCPTransport(context.Background(), hub.Addr()) }},
+		{"multicast", func() (InvalidationTransport, error) { return NewMulticastTransport("239.0.0.1:9999", nil) }},
+	}
+
+	for _, tr := range transports {
+		var caches []*TieredCache[string, string]
+		for i := 0; i < 2; i++ {
+			transport, err := tr.new()
+			if err != nil {
+				logger.Warn("create transport failed", "transport", tr.name, "error", err)
+				break
+			}
+			bus := NewInvalidationBus(transport, logger)
+			defer bus.Close()
+
+			c := NewTieredCache(TieredConfig[string, string]{
+				L2:                  client,
+				Codec:               StringCodec{},
+				Prefix:              tr.name + ":",
+				L1TTL:               time.Minute,
+				Bus:                 bus,
+				RefreshOnInvalidate: true,
+				Logger:              logger,
+			})
+			defer c.Close()
+			caches = append(caches, c)
+		}
+		if len(caches) < 2 {
+			continue
+		}
+
+		a, b := caches[0], caches[1]
+		a.Set("hotkey", "Hot Data", time.Minute)
+		b.Get("hotkey")
+
+		a.Set("hotkey", "Updated by a", time.Minute)
+		// 等待失效消息送达
+		time.Sleep(100 * time.Millisecond)
+		value, _ := b.Get("hotkey")
+		logger.Info("after update", "transport", tr.name, "replica", "b", "key", "hotkey", "value", value)
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Config 选择缓存的防护策略，零值表示不开启任何防护
+type Config[K comparable, V any] struct {
+	// BreakdownLock 合并同一个 key 未命中时的并发加载，防止热点 key 失效时的缓存击穿，
+	// 等待者共享同一次加载的结果和错误，不同 key 的加载并行执行
+	BreakdownLock bool
+
+	// NegativeTTL 大于 0 时开启负缓存，防止缓存穿透：数据源返回 ErrNotFound 后
+	// 在 NegativeTTL 内直接返回 ErrNotFound。负缓存不占用 MaxEntries 和 MaxBytes
+	NegativeTTL time.Duration
+
+	// MaxNegative 限制负缓存的条数，超过时淘汰最早写入的负缓存，
+	// 避免扫描不存在的 key 挤掉正常数据，默认 1024
+	MaxNegative int
+
+	// Filter 不为空时，过滤器判断不存在的 key 直接返回 ErrNotFound，防止缓存穿透。
+	// 通常使用 NewBloomFilter，数据源会删除 key 时使用支持删除的 NewCountingBloomFilter
+	Filter MembershipFilter
+
+	// LoadTimeout 限制单次数据源加载的时间，0 表示不限制
+	LoadTimeout time.Duration
+
+	// MaxConcurrentLoads 大于 0 时限制同时访问数据源的加载数，超出的加载等待，直到 ctx 结束
+	MaxConcurrentLoads int
+
+	// LoadRate 大于 0 时按令牌桶限制每秒访问数据源的次数，没有令牌的加载直接返回 ErrRateLimited
+	LoadRate float64
+
+	// LoadBurst 是令牌桶的容量，默认为 LoadRate 向上取整
+	LoadBurst int
+
+	// BreakerFailures 大于 0 时开启熔断：数据源连续失败 BreakerFailures 次后熔断器打开，
+	// 冷却期间的加载直接返回 ErrCircuitOpen，之后放行一次加载试探，成功后关闭。
+	// 熔断器没有关闭时保留过期数据和负缓存，Fetch 和 GetOrLoad 用它们兜底
+	BreakerFailures int
+
+	// BreakerCooldown 是熔断器打开后到试探之前的冷却时间，默认 5 秒
+	BreakerCooldown time.Duration
+
+	// MaxEntries 大于 0 时限制缓存的数据条数，超出时按 Policy 淘汰
+	MaxEntries int
+
+	// MaxBytes 大于 0 时限制缓存数据估算的总字节数，超出时按 Policy 淘汰
+	MaxBytes int64
+
+	// Sizer 估算一条数据占用的字节数，默认按 string 和 []byte 的长度或者值本身的大小估算
+	Sizer func(key K, value V) int
+
+	// Policy 是容量满时的淘汰策略，默认 LRU
+	Policy EvictionPolicy[K]
+
+	// OnEvict 在数据因为容量或过期被移除后回调，不持有缓存的锁
+	OnEvict func(key K, value V, reason EvictReason)
+
+	// HotKeys 大于 0 时开启热点 key 探测：Get、Fetch 和 GetOrLoad 的访问计入 Count-Min Sketch，
+	// 并维护估算访问次数最多的 HotKeys 个 key，HotKeyReport 返回其中的热点 key。
+	// 访问次数定期减半，不再被访问的热点会逐渐冷却
+	HotKeys int
+
+	// HotKeyThreshold 是成为热点 key 的最少访问次数，默认 10
+	HotKeyThreshold int
+
+	// HotKeyTTL 大于 0 时写入热点 key 的 TTL 至少为 HotKeyTTL
+	HotKeyTTL time.Duration
+
+	// PinHotKeys 为 true 时容量淘汰跳过热点 key，热点 key 只会过期或者被删除
+	PinHotKeys bool
+
+	// RefreshHotKeys 为 true 时热点 key 剩余 TTL 少于 RefreshAhead 的比例时在后台提前刷新，
+	// 没有设置 RefreshAhead 时使用 0.2。只对 GetOrLoad 生效
+	RefreshHotKeys bool
+
+	// Clock 是缓存使用的时钟，默认使用系统时间，测试时可以使用 FakeClock
+	Clock Clock
+
+	// Rand 返回 [0, 1) 之间的随机数，用于 TTL 抖动和 XFetch，需要并发安全，默认使用 rand.Float64。
+	// 测试时可以返回固定的值
+	Rand func() float64
+
+	// Logger 记录命中、加载和淘汰等事件，命中类事件使用 Debug 级别，加载失败使用 Warn 级别，
+	// 为空时不输出
+	Logger *slog.Logger
+
+	// Persist 配置快照和追加日志，重启后从磁盘恢复数据，避免冷启动时的缓存雪崩
+	Persist PersistConfig[K, V]
+
+	// WriteBehind 配置 write-behind，Set 和 Delete 之后异步批量写入后端存储，Close 时写完所有修改
+	WriteBehind WriteBehindConfig[K, V]
+
+	// JanitorInterval 大于 0 时启动后台清理，每个周期抽样删除过期 key，使用完需要调用 Close
+	JanitorInterval time.Duration
+
+	// JanitorSamples 是后台清理每轮抽样的 key 数量，默认 20
+	JanitorSamples int
+
+	// Jitter 为 TTL 增加 [-Jitter, +Jitter] 比例的随机抖动，防止缓存雪崩
+	Jitter float64
+
+	// EarlyRefreshBeta 大于 0 时开启 XFetch 概率提前刷新，防止缓存雪崩：
+	// 命中的请求以 delta*beta*-ln(rand) 的提前量判断是否同步重新加载，
+	// delta 是上次加载的耗时，beta 越大越早刷新，通常取 1。只对 GetOrLoad 生效
+	EarlyRefreshBeta float64
+
+	// StaleTTL 大于 0 时开启 stale-while-revalidate：数据过期后最多再保留 StaleTTL，
+	// 期间 Fetch 和 GetOrLoad 直接返回旧值，并在后台只刷新一次
+	StaleTTL time.Duration
+
+	// RefreshAhead 在 (0, 1) 之间时开启后台提前刷新：剩余 TTL 少于该比例时，
+	// 命中的请求直接返回当前值，并在后台重新加载。只对 GetOrLoad 生效
+	RefreshAhead float64
+}
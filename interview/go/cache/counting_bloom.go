@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"math"
+	"sync"
+
+	"github.com/bits-and-blooms/bloom"
+)
+
+// CountingBloomFilter 用 8 位计数器代替布隆过滤器的位，支持删除 key。
+// 计数器达到上限后不再增减，避免删除时出现误判不存在
+type CountingBloomFilter struct {
+	mu       sync.RWMutex
+	counters []uint8
+	k        uint
+}
+
+var (
+	_ RemovableFilter = (*CountingBloomFilter)(nil)
+	_ FPREstimator    = (*CountingBloomFilter)(nil)
+)
+
+// 根据预计的元素数量和期望的误判率创建计数布隆过滤器，占用的内存是布隆过滤器的 8 倍
+func NewCountingBloomFilter(expectedItems uint, fpRate float64) *CountingBloomFilter {
+	m, k := bloom.EstimateParameters(max(expectedItems, 1), fpRate)
+	return &CountingBloomFilter{
+		counters: make([]uint8, m),
+		k:        k,
+	}
+}
+
+func (f *CountingBloomFilter) Add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, i := range f.locations(key) {
+		if f.counters[i] < 255 {
+			f.counters[i]++
+		}
+	}
+}
+
+func (f *CountingBloomFilter) Test(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.test(f.locations(key))
+}
+
+// Remove 删除 key，只能对 Add 过的 key 调用。没有加入过的 key 误判为存在时，
+// Remove 会减掉其他 key 的计数，让这些 key 误判为不存在，过滤器因此拒绝真实存在的数据。
+// 返回 false 只说明 key 一定没有加入过，返回 true 不能证明 key 加入过
+func (f *CountingBloomFilter) Remove(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	locs := f.locations(key)
+	if !f.test(locs) {
+		return false
+	}
+	for _, i := range locs {
+		if f.counters[i] < 255 {
+			f.counters[i]--
+		}
+	}
+	return true
+}
+
+// EstimatedFPR 按非零计数器的比例估算当前的误判率，删除 key 后估算值会下降
+func (f *CountingBloomFilter) EstimatedFPR() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	nonzero := 0
+	for _, c := range f.counters {
+		if c > 0 {
+			nonzero++
+		}
+	}
+	return math.Pow(float64(nonzero)/float64(len(f.counters)), float64(f.k))
+}
+
+func (f *CountingBloomFilter) test(locs []uint64) bool {
+	for _, i := range locs {
+		if f.counters[i] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 复用布隆过滤器的哈希位置，对计数器数量取模
+func (f *CountingBloomFilter) locations(key string) []uint64 {
+	locs := bloom.Locations([]byte(key), f.k)
+	for i := range locs {
+		locs[i] %= uint64(len(f.counters))
+	}
+	return locs
+}
@@ -0,0 +1,68 @@
+// The demo is for writing behind to a database
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 模拟数据库，每隔几次批量写入失败一次
+type flakyDB struct {
+	mu      sync.Mutex
+	rows    map[string]string
+	batches int
+	calls   int
+}
+
+func (db *flakyDB) WriteBatch(ctx context.Context, writes []Write[string, string]) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.calls++
+	if db.calls%3 == 0 {
+		return errors.New("db: deadlock detected")
+	}
+	db.batches++
+	for _, w := range writes {
+		if w.Delete {
+			delete(db.rows, w.Key)
+		} else {
+			db.rows[w.Key] = w.Value
+		}
+	}
+	return nil
+}
+
+// 模拟 write-behind：1000 次写入 50 个 key 合并成少量批次，失败的批次重试，Close 时写完剩余的修改
+func SimulateWriteBehind() {
+	db := &flakyDB{rows: make(map[string]string)}
+	cache := NewLocalCache(Config[string, string]{
+		WriteBehind: WriteBehindConfig[string, string]{
+			Store:        db,
+			BatchSize:    20,
+			Interval:     50 * time.Millisecond,
+			RetryBackoff: 10 * time.Millisecond,
+		},
+		Logger: logger,
+	})
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key-%d", i%50), fmt.Sprintf("value-%d", i), time.Minute)
+		if i%100 == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	cache.Delete("key-0")
+	logger.Info("before close", "pending", cache.Stats().PendingWrites)
+
+	if err := cache.Close(); err != nil {
+		logger.Warn("close failed", "error", err)
+	}
+	stats := cache.Stats()
+	logger.Info("after close", "rows", len(db.rows), "row", db.rows["key-49"], "batches", db.batches, "calls", db.calls,
+		"flushed", stats.FlushedWrites, "pending", stats.PendingWrites)
+}
@@ -0,0 +1,64 @@
+// The demo is for a degraded backing store
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// 模拟数据库故障：连续失败后熔断器打开，过期的数据继续作为旧值返回，
+// 不存在的 key 快速失败，数据库恢复后试探成功，熔断器关闭。
+// 时间由手动推进的时钟控制，不需要真的等待过期和冷却
+func SimulateCircuitBreaker() {
+	clock := NewFakeClock(time.Now())
+	var down atomic.Bool
+	var queries atomic.Int64
+	queryFromDB := func(ctx context.Context, key string) (string, error) {
+		queries.Add(1)
+		// 模拟数据库延迟
+		clock.Add(20 * time.Millisecond)
+		if down.Load() {
+			return "", errors.New("db: connection refused")
+		}
+		return "Data for " + key, nil
+	}
+
+	cache := NewLocalCache(Config[string, string]{
+		Clock:              clock,
+		BreakdownLock:      true,
+		MaxConcurrentLoads: 4,
+		LoadRate:           100,
+		BreakerFailures:    3,
+		BreakerCooldown:    500 * time.Millisecond,
+		Logger:             logger,
+	})
+	ctx := context.Background()
+
+	cache.GetOrLoad(ctx, "hotkey", 100*time.Millisecond, queryFromDB)
+	down.Store(true)
+	clock.Add(150 * time.Millisecond)
+
+	// 数据库故障期间热点 key 已经过期，新 key 连续失败打开熔断器
+	for i := 0; i < 10; i++ {
+		key := "key-" + string(rune('a'+i))
+		if _, err := cache.GetOrLoad(ctx, key, time.Second, queryFromDB); err != nil {
+			logger.Info("load failed", "key", key, "error", err)
+		}
+	}
+	res, err := cache.Fetch(ctx, "hotkey", 100*time.Millisecond, queryFromDB)
+	logger.Info("got value", "key", "hotkey", "value", res.Value, "stale", res.Stale, "error", err)
+
+	// 冷却结束后数据库恢复，试探成功关闭熔断器
+	down.Store(false)
+	clock.Add(600 * time.Millisecond)
+	value, err := cache.GetOrLoad(ctx, "key-a", time.Second, queryFromDB)
+	logger.Info("got value", "key", "key-a", "value", value, "error", err)
+
+	stats := cache.Stats()
+	logger.Info("breaker stats", "queries", queries.Load(), "breaker", stats.Breaker.String(),
+		"opens", stats.BreakerOpens, "circuit_rejected", stats.CircuitRejected,
+		"rate_limited", stats.RateLimited, "stale_hits", stats.StaleHits)
+}
@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultEventBuffer = 64
+
+// EventType 是缓存事件的类型
+type EventType int
+
+const (
+	// EventSet 表示写入数据，包括加载后回填
+	EventSet EventType = iota
+	// EventExpire 表示数据过期后被删除
+	EventExpire
+	// EventEvict 表示数据超出容量被淘汰
+	EventEvict
+	// EventInvalidate 表示数据被 Delete、Purge 或者失效消息删除
+	EventInvalidate
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventInvalidate:
+		return "invalidate"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 是一次缓存事件，Value 是写入或者被移除的值
+type Event[K comparable, V any] struct {
+	Type  EventType
+	Key   K
+	Value V
+	Time  time.Time
+}
+
+// OverflowPolicy 决定订阅的缓冲区满时如何处理新事件
+type OverflowPolicy int
+
+const (
+	// DropNewest 丢弃新事件，不影响缓存的读写
+	DropNewest OverflowPolicy = iota
+	// DropOldest 丢弃缓冲区中最早的事件，保留最新的
+	DropOldest
+	// Block 等待订阅者取走事件，产生事件的写入会被阻塞，直到订阅被关闭
+	Block
+)
+
+// SubscribeOptions 配置一个订阅
+type SubscribeOptions struct {
+	// Buffer 是事件通道的容量，默认 64
+	Buffer int
+
+	// Overflow 是缓冲区满时的处理方式，默认 DropNewest
+	Overflow OverflowPolicy
+
+	// Types 只订阅指定类型的事件，为空时订阅所有事件
+	Types []EventType
+}
+
+// Subscription 是一个事件订阅，从 C 读取事件，不再需要时调用 Close
+type Subscription[K comparable, V any] struct {
+	C <-chan Event[K, V]
+
+	ch       chan Event[K, V]
+	hub      *eventHub[K, V]
+	overflow OverflowPolicy
+	// types 的第 i 位表示订阅了 EventType(i)
+	types   uint
+	dropped atomic.Uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Dropped 返回因为缓冲区满被丢弃的事件数
+func (s *Subscription[K, V]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close 取消订阅并关闭 C，被阻塞的写入会立即返回
+func (s *Subscription[K, V]) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.hub.mu.Lock()
+		delete(s.hub.subs, s)
+		s.hub.count.Store(int32(len(s.hub.subs)))
+		s.hub.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+func (s *Subscription[K, V]) deliver(e Event[K, V]) {
+	switch s.overflow {
+	case Block:
+		select {
+		case s.ch <- e:
+		case <-s.done:
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- e:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// eventHub 把事件分发给所有订阅，分片缓存的所有分片共用一个。
+// 事件在修改数据的同一把锁内进入队列，释放锁之后由 flush 按入队顺序发送，
+// 并发写入同一个 key 时订阅者收到的顺序和写入内存的顺序一致
+type eventHub[K comparable, V any] struct {
+	clock Clock
+	mu    sync.RWMutex
+	subs  map[*Subscription[K, V]]struct{}
+	// 没有订阅时跳过加锁
+	count atomic.Int32
+
+	qmu    sync.Mutex
+	queue  []Event[K, V]
+	queued atomic.Int32
+	// 同一时间只有一个 goroutine 发送，保证队列中的事件按顺序到达
+	sending sync.Mutex
+}
+
+func newEventHub[K comparable, V any](clock Clock) *eventHub[K, V] {
+	return &eventHub[K, V]{clock: clock, subs: make(map[*Subscription[K, V]]struct{})}
+}
+
+func (h *eventHub[K, V]) subscribe(opts SubscribeOptions) *Subscription[K, V] {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultEventBuffer
+	}
+	s := &Subscription[K, V]{
+		ch:       make(chan Event[K, V], opts.Buffer),
+		hub:      h,
+		overflow: opts.Overflow,
+		types:    ^uint(0),
+		done:     make(chan struct{}),
+	}
+	s.C = s.ch
+	if len(opts.Types) > 0 {
+		s.types = 0
+		for _, t := range opts.Types {
+			s.types |= 1 << uint(t)
+		}
+	}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.count.Store(int32(len(h.subs)))
+	h.mu.Unlock()
+	return s
+}
+
+// enqueue 把事件放入队列，调用方持有修改数据的锁，释放锁之后需要调用 flush
+func (h *eventHub[K, V]) enqueue(typ EventType, key K, value V) {
+	if h.count.Load() == 0 {
+		return
+	}
+	e := Event[K, V]{Type: typ, Key: key, Value: value, Time: h.clock.Now()}
+
+	h.qmu.Lock()
+	h.queue = append(h.queue, e)
+	h.queued.Store(int32(len(h.queue)))
+	h.qmu.Unlock()
+}
+
+// flush 按顺序发送队列中的事件，调用方不能持有缓存的锁，Block 策略下会等待订阅者。
+// 其他 goroutine 正在发送时等待它发送完，再发送之后入队的事件
+func (h *eventHub[K, V]) flush() {
+	if h.queued.Load() == 0 {
+		return
+	}
+	h.sending.Lock()
+	defer h.sending.Unlock()
+
+	for {
+		h.qmu.Lock()
+		events := h.queue
+		h.queue = nil
+		h.queued.Store(0)
+		h.qmu.Unlock()
+		if len(events) == 0 {
+			return
+		}
+
+		// 持有读锁发送，Close 拿到写锁之后才关闭通道
+		h.mu.RLock()
+		for _, e := range events {
+			for s := range h.subs {
+				if s.types&(1<<uint(e.Type)) != 0 {
+					s.deliver(e)
+				}
+			}
+		}
+		h.mu.RUnlock()
+	}
+}
+
+// Subscribe 订阅缓存事件，加载后的回填也会产生 EventSet，负缓存不产生事件
+func (c *LocalCache[K, V]) Subscribe(opts SubscribeOptions) *Subscription[K, V] {
+	return c.events.subscribe(opts)
+}
+
+// Subscribe 订阅所有分片的缓存事件
+func (c *ShardedCache[K, V]) Subscribe(opts SubscribeOptions) *Subscription[K, V] {
+	return c.shards[0].events.subscribe(opts)
+}
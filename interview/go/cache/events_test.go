@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recv 取出通道中已有的事件
+func recv[K comparable, V any](sub *Subscription[K, V]) []Event[K, V] {
+	var events []Event[K, V]
+	for {
+		select {
+		case e := <-sub.C:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// 并发写入同一个 key 时，最后一个 EventSet 的值必须是内存中的值
+func TestEventOrderMatchesMemory(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		cache := NewLocalCache(Config[string, int]{})
+		sub := cache.Subscribe(SubscribeOptions{Buffer: 1, Overflow: DropOldest})
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					cache.Set("key", g*1000+i, time.Hour)
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		events := recv(sub)
+		value, _ := cache.Get("key")
+		if len(events) != 1 || events[0].Value != value {
+			t.Fatalf("last event %v, memory %d", events, value)
+		}
+		sub.Close()
+		cache.Close()
+	}
+}
+
+func TestEventTypes(t *testing.T) {
+	cache := NewLocalCache(Config[string, int]{MaxEntries: 1})
+	defer cache.Close()
+	all := cache.Subscribe(SubscribeOptions{})
+	defer all.Close()
+	removals := cache.Subscribe(SubscribeOptions{Types: []EventType{EventEvict, EventInvalidate}})
+	defer removals.Close()
+
+	cache.Set("a", 1, time.Hour)
+	cache.Set("b", 2, time.Hour)
+	cache.Delete("b")
+	cache.Delete("missing")
+
+	want := []Event[string, int]{
+		{Type: EventSet, Key: "a", Value: 1},
+		{Type: EventSet, Key: "b", Value: 2},
+		{Type: EventEvict, Key: "a", Value: 1},
+		{Type: EventInvalidate, Key: "b", Value: 2},
+	}
+	check := func(name string, got, want []Event[string, int]) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d events %v, want %v", name, len(got), got, want)
+		}
+		for i := range want {
+			if got[i].Type != want[i].Type || got[i].Key != want[i].Key || got[i].Value != want[i].Value {
+				t.Fatalf("%s: event %d = %v %s=%d, want %v %s=%d", name, i,
+					got[i].Type, got[i].Key, got[i].Value, want[i].Type, want[i].Key, want[i].Value)
+			}
+		}
+	}
+	check("all", recv(all), want)
+	check("removals", recv(removals), want[2:])
+}
+
+func TestEventExpire(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{Clock: clock})
+	defer cache.Close()
+	sub := cache.Subscribe(SubscribeOptions{Types: []EventType{EventExpire}})
+	defer sub.Close()
+
+	cache.Set("key", 1, time.Second)
+	clock.Add(2 * time.Second)
+	cache.Get("key")
+	if events := recv(sub); len(events) != 1 || events[0].Key != "key" || !events[0].Time.Equal(epoch.Add(2*time.Second)) {
+		t.Fatalf("got %v, want one expire event stamped by the Clock", events)
+	}
+}
+
+func TestEventOverflow(t *testing.T) {
+	tests := []struct {
+		overflow OverflowPolicy
+		want     []int
+	}{
+		{DropNewest, []int{0, 1}},
+		{DropOldest, []int{3, 4}},
+	}
+
+	for _, tt := range tests {
+		cache := NewLocalCache(Config[string, int]{})
+		sub := cache.Subscribe(SubscribeOptions{Buffer: 2, Overflow: tt.overflow})
+		for i := 0; i < 5; i++ {
+			cache.Set("key", i, time.Hour)
+		}
+
+		events := recv(sub)
+		if len(events) != 2 || events[0].Value != tt.want[0] || events[1].Value != tt.want[1] || sub.Dropped() != 3 {
+			t.Errorf("overflow %d: got %v, dropped %d, want values %v and 3 dropped", tt.overflow, events, sub.Dropped(), tt.want)
+		}
+		sub.Close()
+		cache.Close()
+	}
+}
+
+func TestEventBlockAndClose(t *testing.T) {
+	cache := NewLocalCache(Config[string, int]{})
+	defer cache.Close()
+	sub := cache.Subscribe(SubscribeOptions{Buffer: 1, Overflow: Block})
+
+	cache.Set("key", 1, time.Hour)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Set("key", 2, time.Hour)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Set returned while the Block subscription was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if e := <-sub.C; e.Value != 1 {
+		t.Fatalf("first event value %d, want 1", e.Value)
+	}
+	if e := <-sub.C; e.Value != 2 {
+		t.Fatalf("second event value %d, want 2", e.Value)
+	}
+	<-done
+
+	// Close 让被阻塞的写入立即返回并关闭 C
+	cache.Set("key", 3, time.Hour)
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		cache.Set("key", 4, time.Hour)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sub.Close()
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Set still blocked after Close")
+	}
+	for range sub.C {
+	}
+	cache.Set("key", 5, time.Hour)
+	if value, _ := cache.Get("key"); value != 5 {
+		t.Fatalf("Get = %d after Close, want 5", value)
+	}
+}
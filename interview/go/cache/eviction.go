@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"unsafe"
+)
+
+// EvictionPolicy 决定容量满时淘汰哪个 key。
+// 缓存会串行调用它的方法，实现不需要并发安全
+type EvictionPolicy[K comparable] interface {
+	// Add 记录新写入的 key
+	Add(key K)
+	// Access 记录 key 被访问或者被覆盖写入，key 不存在时忽略
+	Access(key K)
+	// Remove 移除被删除或者过期的 key，key 不存在时忽略
+	Remove(key K)
+	// Victim 选出并移除下一个被淘汰的 key，没有 key 时返回 false
+	Victim() (K, bool)
+	// Reinsert 放回刚刚由 Victim 选出但没有被淘汰的 key，当作刚被访问过，
+	// 不能当作淘汰后再次写入，例如 ARC 不能把它算作命中 B1、B2
+	Reinsert(key K)
+}
+
+// EvictReason 是数据被移除的原因
+type EvictReason int
+
+const (
+	// EvictCapacity 表示超出容量被淘汰
+	EvictCapacity EvictReason = iota
+	// EvictExpired 表示过期后被清理
+	EvictExpired
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	negative bool
+}
+
+// store 写入数据并在超出容量时淘汰，返回被淘汰的数据，调用方持有写锁。
+// 负缓存不参与容量统计和淘汰策略，单独限制条数
+func (c *LocalCache[K, V]) store(key K, it item[V]) []entry[K, V] {
+	old, found := c.data[key]
+	c.data[key] = it
+	c.peak = max(c.peak, len(c.data))
+
+	if found && old.negative {
+		c.negatives.Remove(key)
+		found = false
+	}
+	if it.negative {
+		if found {
+			c.forget(key, old)
+		}
+		c.storeNegative(key)
+		return nil
+	}
+
+	c.bytes += int64(it.size - old.size)
+	if c.policy == nil {
+		return nil
+	}
+
+	c.policyMu.Lock()
+	defer c.policyMu.Unlock()
+
+	if found {
+		c.policy.Access(key)
+	} else {
+		c.policy.Add(key)
+	}
+
+	var evicted []entry[K, V]
+	// 被跳过的热点 key 放回淘汰策略，最多跳过 HotKeys 次，避免全是热点时死循环
+	skips := c.cfg.HotKeys
+	for c.overflow() {
+		victim, ok := c.policy.Victim()
+		if !ok {
+			break
+		}
+		if skips > 0 && victim != key && c.pinned(victim) {
+			skips--
+			c.policy.Reinsert(victim)
+			continue
+		}
+		if v, found := c.data[victim]; found {
+			delete(c.data, victim)
+			c.bytes -= int64(v.size)
+			evicted = append(evicted, entry[K, V]{victim, v.value, false})
+		}
+	}
+	return evicted
+}
+
+// remove 删除数据，调用方持有写锁
+func (c *LocalCache[K, V]) remove(key K, it item[V]) {
+	delete(c.data, key)
+	if it.negative {
+		c.negatives.Remove(key)
+		return
+	}
+	c.forget(key, it)
+}
+
+// forget 从容量统计和淘汰策略中去掉一条正常数据，调用方持有写锁
+func (c *LocalCache[K, V]) forget(key K, it item[V]) {
+	c.bytes -= int64(it.size)
+
+	if c.policy != nil {
+		c.policyMu.Lock()
+		c.policy.Remove(key)
+		c.policyMu.Unlock()
+	}
+}
+
+func (c *LocalCache[K, V]) touch(key K) {
+	if c.policy == nil {
+		return
+	}
+
+	c.policyMu.Lock()
+	c.policy.Access(key)
+	c.policyMu.Unlock()
+}
+
+func (c *LocalCache[K, V]) overflow() bool {
+	return (c.cfg.MaxEntries > 0 && len(c.data)-c.negativeLen() > c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes)
+}
+
+// queueEvicted 把被移除的数据放入事件队列，调用方持有写锁，负缓存不产生事件
+func (c *LocalCache[K, V]) queueEvicted(evicted []entry[K, V], reason EvictReason) {
+	typ := EventEvict
+	if reason == EvictExpired {
+		typ = EventExpire
+	}
+	for _, e := range evicted {
+		if !e.negative {
+			c.events.enqueue(typ, e.key, e.value)
+		}
+	}
+}
+
+// notifyEvicted 统计被移除的数据并回调 OnEvict，调用方不持有锁
+func (c *LocalCache[K, V]) notifyEvicted(evicted []entry[K, V], reason EvictReason) {
+	switch reason {
+	case EvictCapacity:
+		c.counters.evictions.Add(uint64(len(evicted)))
+	case EvictExpired:
+		c.counters.expirations.Add(uint64(len(evicted)))
+	}
+
+	for _, e := range evicted {
+		c.debug(context.Background(), "cache evict", e.key, slog.String("reason", reason.String()))
+		if c.cfg.OnEvict != nil {
+			c.cfg.OnEvict(e.key, e.value, reason)
+		}
+	}
+}
+
+func (c *LocalCache[K, V]) sizeOf(key K, value V) int {
+	if c.cfg.MaxBytes <= 0 {
+		return 0
+	}
+	if c.cfg.Sizer != nil {
+		return c.cfg.Sizer(key, value)
+	}
+	return estimateSize(key) + estimateSize(value)
+}
+
+// estimateSize 粗略估算值占用的字节数，string 和 []byte 加上底层数组的长度
+func estimateSize[T any](v T) int {
+	size := int(unsafe.Sizeof(v))
+	switch x := any(v).(type) {
+	case string:
+		size += len(x)
+	case []byte:
+		size += cap(x)
+	}
+	return size
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// drain 依次取出所有被淘汰的 key
+func drain[K comparable](p EvictionPolicy[K]) []K {
+	var keys []K
+	for {
+		key, ok := p.Victim()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EvictionPolicy[string]
+		want   []string
+	}{
+		// 最久没有访问的先淘汰
+		{"LRU", NewLRU[string](), []string{"b", "d", "a", "c"}},
+		// 访问次数少的先淘汰，次数相同时最久没有访问的先淘汰
+		{"LFU", NewLFU[string](), []string{"b", "d", "c", "a"}},
+		// 只访问过一次的 T1 先于访问过多次的 T2 淘汰，各自按最久没有访问淘汰
+		{"ARC", NewARC[string](4), []string{"b", "d", "a", "c"}},
+	}
+
+	for _, tt := range tests {
+		p := tt.policy
+		for _, key := range []string{"a", "b", "c", "d"} {
+			p.Add(key)
+		}
+		p.Access("a")
+		p.Access("a")
+		p.Access("c")
+		p.Access("missing")
+
+		if got := drain(p); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: evicted %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvictionRemove(t *testing.T) {
+	for name, p := range map[string]EvictionPolicy[string]{
+		"LRU": NewLRU[string](),
+		"LFU": NewLFU[string](),
+		"ARC": NewARC[string](4),
+	} {
+		p.Add("a")
+		p.Add("b")
+		p.Remove("a")
+		p.Remove("missing")
+		if got := drain(p); !reflect.DeepEqual(got, []string{"b"}) {
+			t.Errorf("%s: evicted %v after Remove, want [b]", name, got)
+		}
+	}
+}
+
+func TestEvictionReinsert(t *testing.T) {
+	lfu := NewLFU[string]()
+	lfu.Add("a")
+	lfu.Add("b")
+	lfu.Access("b")
+	lfu.Access("b")
+	lfu.Add("c")
+	lfu.Access("c")
+	victim, _ := lfu.Victim()
+	lfu.Reinsert(victim)
+	// a 放回后仍然只有 1 次访问
+	if got := drain[string](lfu); !reflect.DeepEqual(got, []string{"a", "c", "b"}) {
+		t.Errorf("LFU: evicted %v after Reinsert, want [a c b]", got)
+	}
+
+	arc := NewARC[string](4)
+	arc.Add("a")
+	arc.Add("b")
+	victim, _ = arc.Victim()
+	arc.Reinsert(victim)
+	if arc.p != 0 || arc.items["a"].list != arc.t1 {
+		t.Errorf("ARC: Reinsert counted as a ghost hit, p = %d", arc.p)
+	}
+	if got := drain[string](arc); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("ARC: evicted %v after Reinsert, want [b a]", got)
+	}
+
+	// 淘汰后再次写入才调整 p
+	arc = NewARC[string](4)
+	arc.Add("a")
+	arc.Add("b")
+	victim, _ = arc.Victim()
+	arc.Add(victim)
+	if arc.p != 1 {
+		t.Errorf("ARC: p = %d after a B1 hit, want 1", arc.p)
+	}
+}
+
+// 跳过热点 key 时不能让 ARC 把它当作命中 B1
+func TestPinnedVictimUnderARC(t *testing.T) {
+	// ARC 的容量大于 MaxEntries，被跳过的 key 在 B1 中不会立即被丢弃
+	arc := NewARC[string](4)
+	cache := NewLocalCache(Config[string, int]{
+		MaxEntries:      2,
+		Policy:          arc,
+		HotKeys:         4,
+		HotKeyThreshold: 3,
+		PinHotKeys:      true,
+	})
+	defer cache.Close()
+
+	cache.Set("hot", 1, time.Hour)
+	for i := 0; i < 5; i++ {
+		cache.hot.record("hot")
+	}
+	cache.Set("b", 2, time.Hour)
+	cache.Set("c", 3, time.Hour)
+
+	if _, found := cache.Get("hot"); !found {
+		t.Fatal("evicted the pinned hot key")
+	}
+	if _, found := cache.Get("b"); found {
+		t.Fatal("kept b, want it evicted after skipping the hot key")
+	}
+	if arc.p != 0 {
+		t.Fatalf("ARC p = %d after skipping a pinned key, want 0", arc.p)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	var evicted []string
+	cache := NewLocalCache(Config[string, int]{
+		MaxEntries: 2,
+		OnEvict: func(key string, value int, reason EvictReason) {
+			if reason == EvictCapacity {
+				evicted = append(evicted, key)
+			}
+		},
+	})
+	defer cache.Close()
+
+	cache.Set("a", 1, time.Hour)
+	cache.Set("b", 2, time.Hour)
+	cache.Get("a")
+	cache.Set("c", 3, time.Hour)
+	// 覆盖写入不增加条数
+	cache.Set("c", 4, time.Hour)
+
+	if cache.Len() != 2 || !reflect.DeepEqual(evicted, []string{"b"}) {
+		t.Fatalf("Len = %d, evicted %v, want 2 entries and [b]", cache.Len(), evicted)
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 {
+		t.Fatalf("Evictions = %d, want 1", stats.Evictions)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	cache := NewLocalCache(Config[string, string]{
+		MaxBytes: 10,
+		Sizer:    func(key, value string) int { return len(value) },
+	})
+	defer cache.Close()
+
+	cache.Set("a", "12345", time.Hour)
+	cache.Set("b", "12345", time.Hour)
+	if cache.bytes != 10 || cache.Len() != 2 {
+		t.Fatalf("bytes = %d, Len = %d, want 10 and 2 at the limit", cache.bytes, cache.Len())
+	}
+
+	// 覆盖写入按新旧大小的差值统计
+	cache.Set("a", "1", time.Hour)
+	if cache.bytes != 6 {
+		t.Fatalf("bytes = %d after overwrite, want 6", cache.bytes)
+	}
+
+	cache.Set("c", "12345", time.Hour)
+	if _, found := cache.Get("b"); found || cache.bytes != 6 {
+		t.Fatalf("bytes = %d, b found %v, want b evicted and 6 bytes", cache.bytes, found)
+	}
+
+	cache.Delete("a")
+	if cache.bytes != 5 {
+		t.Fatalf("bytes = %d after Delete, want 5", cache.bytes)
+	}
+}
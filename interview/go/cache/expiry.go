@@ -0,0 +1,52 @@
+// The demo is for lazy expiry deletion under concurrency
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// 模拟读者惰性删除过期数据的同时写者刷新同一个 key，
+// 写者刷新后立即读取必须命中，否则说明新数据被误删。
+// 建议使用 go run -race 运行
+func SimulateExpiryRace() {
+	cache := NewLocalCache(Config[string, int]{})
+	defer cache.Close()
+
+	lost := raceLazyExpiry(cache, 8, 100000)
+	logger.Info("expiry race finished", "lost", lost)
+}
+
+// raceLazyExpiry 让 readers 个读者不停读取 key，同时写入 rounds 次即过期的数据再立即刷新，
+// 返回刷新后立即读取没有命中或者读到旧值的次数
+func raceLazyExpiry(cache *LocalCache[string, int], readers, rounds int) int {
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					cache.Get("key")
+				}
+			}
+		}()
+	}
+
+	lost := 0
+	for i := 0; i < rounds; i++ {
+		cache.Set("key", i, -time.Second) // 写入即过期，读者会尝试删除它
+		cache.Set("key", i, time.Hour)
+		if value, found := cache.Get("key"); !found || value != i {
+			lost++
+		}
+	}
+	close(stop)
+	wg.Wait()
+	return lost
+}
@@ -0,0 +1,32 @@
+package cache
+
+// MembershipFilter 判断 key 是否可能存在于数据源，允许误判存在，不允许误判不存在。
+// 实现需要并发安全
+type MembershipFilter interface {
+	// Add 记录 key 存在
+	Add(key string)
+	// Test 返回 key 是否可能存在
+	Test(key string) bool
+}
+
+// RemovableFilter 是支持删除的 MembershipFilter
+type RemovableFilter interface {
+	MembershipFilter
+	// Remove 删除之前 Add 过的 key，key 不存在时返回 false。
+	// 不能删除没有 Add 过的 key，否则误判存在的 key 会减掉其他 key 的计数，产生误判不存在
+	Remove(key string) bool
+}
+
+// FPREstimator 是可以估算当前误判率的过滤器，key 超出预计数量后误判率会持续升高
+type FPREstimator interface {
+	EstimatedFPR() float64
+}
+
+// Purge 在数据源删除 key 后调用，删除缓存数据，过滤器支持删除时同时从过滤器中删除。
+// 只能对数据源中存在过、已经加入过滤器的 key 调用
+func (c *LocalCache[K, V]) Purge(key K) {
+	c.Delete(key)
+	if f, ok := c.cfg.Filter.(RemovableFilter); ok {
+		f.Remove(keyString(key))
+	}
+}
@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	filterItems  = 10000
+	filterProbes = 100000
+	filterTarget = 0.01
+)
+
+// 测量过滤器的误判率：先加入 n 个 key，再用 probes 个从未加入的 key 测试
+func measureFalsePositiveRate(f MembershipFilter, n, probes int) float64 {
+	for i := 0; i < n; i++ {
+		f.Add(fmt.Sprintf("member-%d", i))
+	}
+
+	fp := 0
+	for i := 0; i < probes; i++ {
+		if f.Test(fmt.Sprintf("absent-%d", i)) {
+			fp++
+		}
+	}
+	return float64(fp) / float64(probes)
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	filters := []struct {
+		name   string
+		filter MembershipFilter
+		items  int
+	}{
+		{"bloom", NewBloomFilter(filterItems, filterTarget), filterItems},
+		{"counting bloom", NewCountingBloomFilter(filterItems, filterTarget), filterItems},
+		// key 的数量超出预计 10 倍时，可扩容的布隆过滤器误判率仍然有上限
+		{"scalable bloom", NewScalableBloomFilter(filterItems/10, filterTarget), filterItems},
+	}
+
+	for _, f := range filters {
+		t.Run(f.name, func(t *testing.T) {
+			if rate := measureFalsePositiveRate(f.filter, f.items, filterProbes); rate > 2*filterTarget {
+				t.Errorf("false positive rate %.4f, want <= %.4f", rate, 2*filterTarget)
+			}
+			for i := 0; i < f.items; i++ {
+				if !f.filter.Test(fmt.Sprintf("member-%d", i)) {
+					t.Fatalf("member-%d not found", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBloomFilterOverflow(t *testing.T) {
+	f := NewBloomFilter(filterItems/10, filterTarget)
+	if rate := measureFalsePositiveRate(f, filterItems, filterProbes); rate < 10*filterTarget {
+		t.Errorf("false positive rate %.4f with 10x keys, want it to exceed %.4f", rate, 10*filterTarget)
+	}
+	if estimated := f.EstimatedFPR(); estimated < 10*filterTarget {
+		t.Errorf("estimated false positive rate %.4f, want it to exceed %.4f", estimated, 10*filterTarget)
+	}
+}
+
+func TestCountingBloomFilterRemove(t *testing.T) {
+	f := NewCountingBloomFilter(filterItems, filterTarget)
+	measureFalsePositiveRate(f, filterItems, 0)
+
+	for i := 0; i < filterItems/2; i++ {
+		if !f.Remove(fmt.Sprintf("member-%d", i)) {
+			t.Fatalf("Remove(member-%d) = false", i)
+		}
+	}
+
+	passed := 0
+	for i := 0; i < filterItems/2; i++ {
+		if f.Test(fmt.Sprintf("member-%d", i)) {
+			passed++
+		}
+	}
+	if rate := float64(passed) / float64(filterItems/2); rate > 2*filterTarget {
+		t.Errorf("removed keys still passing at %.4f, want <= %.4f", rate, 2*filterTarget)
+	}
+	for i := filterItems / 2; i < filterItems; i++ {
+		if !f.Test(fmt.Sprintf("member-%d", i)) {
+			t.Fatalf("member-%d lost after removing other keys", i)
+		}
+	}
+}
+
+// 删除没有加入过但误判存在的 key 会减掉成员的计数，成员变成误判不存在
+func TestCountingBloomFilterRemoveUnknownKey(t *testing.T) {
+	const members = 100
+	f := NewCountingBloomFilter(members, 0.1)
+	measureFalsePositiveRate(f, members, 0)
+
+	lost := 0
+	for probe := 0; probe < 100000 && lost == 0; probe++ {
+		key := fmt.Sprintf("probe-%d", probe)
+		if !f.Test(key) {
+			// 一定没有加入过，Remove 不会修改计数
+			if f.Remove(key) {
+				t.Fatalf("Remove(%s) = true for a key that tests absent", key)
+			}
+			continue
+		}
+
+		// 误判存在，Remove 无法区分它和真实的成员
+		if !f.Remove(key) {
+			t.Fatalf("Remove(%s) = false for a false positive", key)
+		}
+		for i := 0; i < members; i++ {
+			if !f.Test(fmt.Sprintf("member-%d", i)) {
+				lost++
+			}
+		}
+	}
+	if lost == 0 {
+		t.Fatal("removing false positives never produced a false negative")
+	}
+}
+
+// sliceSource 返回遍历 keys 的 KeySource，并记录被遍历的次数
+func sliceSource(keys []string, scans *int) KeySource {
+	return func(ctx context.Context, emit func(key string) error) error {
+		*scans++
+		for _, key := range keys {
+			if err := emit(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestLoadOrWarmBloomFilter(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.bloom")
+	keys := []string{"key1", "key2", "key3"}
+	scans := 0
+	src := sliceSource(keys, &scans)
+
+	warm := func(expected uint, fpRate float64) *BloomFilter {
+		t.Helper()
+		f, err := LoadOrWarmBloomFilter(ctx, path, expected, fpRate, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if !f.Test(key) {
+				t.Fatalf("filter lost %q", key)
+			}
+		}
+		return f
+	}
+	corrupt := func(change func(data []byte) []byte) {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, change(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadBloomFilter(path); !errors.Is(err, ErrCorruptFilter) {
+			t.Fatalf("LoadBloomFilter = %v, want ErrCorruptFilter", err)
+		}
+	}
+
+	f := warm(1000, 0.01)
+	if scans != 1 || f.Len() != 3 {
+		t.Fatalf("scans = %d, Len = %d, want the first start to warm 3 keys", scans, f.Len())
+	}
+
+	// 参数相同时直接从文件恢复
+	loaded := warm(1000, 0.01)
+	if scans != 1 || loaded.Len() != 3 || loaded.Cap() != f.Cap() || loaded.K() != f.K() {
+		t.Fatalf("scans = %d, loaded Len %d m %d k %d, want the saved filter", scans, loaded.Len(), loaded.Cap(), loaded.K())
+	}
+
+	// 损坏或者不完整的文件重新预热，并覆盖旧文件
+	corrupt(func(data []byte) []byte {
+		data[headerSize+bloomParamsSize+20]++
+		return data
+	})
+	warm(1000, 0.01)
+	corrupt(func(data []byte) []byte { return data[:len(data)/2] })
+	warm(1000, 0.01)
+	if scans != 3 {
+		t.Fatalf("scans = %d, want a rewarm for each corrupt file", scans)
+	}
+	warm(1000, 0.01)
+
+	// 参数变化后按新参数重建
+	f = warm(100000, 0.001)
+	if scans != 4 || f.Cap() <= loaded.Cap() {
+		t.Fatalf("scans = %d, m = %d, want a rewarm with a larger filter than %d", scans, f.Cap(), loaded.Cap())
+	}
+}
+
+// setFilter 是没有误判的过滤器，用于检查重建后的内容
+type setFilter map[string]struct{}
+
+func (f setFilter) Add(key string) {
+	f[key] = struct{}{}
+}
+
+func (f setFilter) Test(key string) bool {
+	_, found := f[key]
+	return found
+}
+
+// gatedSource 每次遍历前通知 scans，等待 release 给出这次遍历的结果
+type gatedSource struct {
+	keys    []string
+	scans   chan struct{}
+	release chan error
+}
+
+func (s *gatedSource) scan(ctx context.Context, emit func(key string) error) error {
+	s.scans <- struct{}{}
+	if err := <-s.release; err != nil {
+		return err
+	}
+	for _, key := range s.keys {
+		if err := emit(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRotatingFilterWindow(t *testing.T) {
+	const interval = time.Minute
+	clock := NewFakeClock(epoch)
+	src := &gatedSource{
+		keys:    []string{"a", "b"},
+		scans:   make(chan struct{}, 1),
+		release: make(chan error, 1),
+	}
+	var expected []uint
+	newFilter := func(n uint) MembershipFilter {
+		expected = append(expected, n)
+		return setFilter{}
+	}
+
+	src.release <- nil
+	f, err := NewRotatingFilter(context.Background(), src.scan, interval, clock, newFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	<-src.scans
+
+	scanned := func() bool {
+		select {
+		case <-src.scans:
+			return true
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !done(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+
+	// 周期结束前不重建
+	clock.Add(interval - time.Nanosecond)
+	if scanned() {
+		t.Fatal("rotated before the interval")
+	}
+
+	// 恰好到达周期时重建，新的过滤器按上一次 key 数量的两倍创建，清除数据源中删除的 key
+	clock.Add(time.Nanosecond)
+	if !scanned() {
+		t.Fatal("did not rotate at the interval")
+	}
+	src.keys = []string{"b", "c"}
+	src.release <- nil
+	waitFor("rotation", func() bool { return f.Rotations() == 2 })
+	if f.Test("a") || !f.Test("b") || !f.Test("c") {
+		t.Fatal("rotated filter does not match the source")
+	}
+
+	// 一次跳过多个周期时只重建一次
+	clock.Add(3 * interval)
+	if !scanned() {
+		t.Fatal("did not rotate after several intervals")
+	}
+	src.release <- nil
+	waitFor("rotation", func() bool { return f.Rotations() == 3 })
+	if scanned() {
+		t.Fatal("rotated more than once after several intervals")
+	}
+
+	// 重建期间 Add 的 key 补到新的过滤器中
+	clock.Add(interval)
+	if !scanned() {
+		t.Fatal("did not rotate at the next interval")
+	}
+	f.Add("during")
+	src.release <- nil
+	waitFor("rotation", func() bool { return f.Rotations() == 4 })
+	if !f.Test("during") {
+		t.Fatal("lost key added during rotation")
+	}
+
+	// 重建失败时继续使用旧的过滤器
+	clock.Add(interval)
+	if !scanned() {
+		t.Fatal("did not rotate at the next interval")
+	}
+	src.keys = nil
+	src.release <- errors.New("source unavailable")
+	waitFor("failure", func() bool { return f.Failures() == 1 })
+	if f.Rotations() != 4 || !f.Test("b") || !f.Test("during") {
+		t.Fatal("failed rotation replaced the filter")
+	}
+
+	want := []uint{1, 4, 4, 4, 4}
+	if fmt.Sprint(expected) != fmt.Sprint(want) {
+		t.Fatalf("expected items = %v, want %v", expected, want)
+	}
+
+	f.Close()
+	clock.Add(interval)
+	if scanned() {
+		t.Fatal("rotated after Close")
+	}
+}
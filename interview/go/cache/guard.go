@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultBreakerCooldown = 5 * time.Second
+
+var (
+	// ErrRateLimited 表示加载超过了 LoadRate，没有访问数据源
+	ErrRateLimited = errors.New("cache: load rate limited")
+	// ErrCircuitOpen 表示熔断器打开，没有访问数据源
+	ErrCircuitOpen = errors.New("cache: circuit breaker open")
+)
+
+// BreakerState 是熔断器的状态
+type BreakerState int
+
+const (
+	// BreakerClosed 表示正常访问数据源
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 表示数据源连续失败，加载直接返回 ErrCircuitOpen
+	BreakerOpen
+	// BreakerHalfOpen 表示冷却结束，放行一次加载试探数据源是否恢复
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// loadGuard 依次用令牌桶、熔断器和并发数限制访问数据源，方法可以在 nil 上调用
+type loadGuard struct {
+	bucket  *tokenBucket
+	breaker *breaker
+	sem     chan struct{}
+
+	rateLimited, circuitRejected atomic.Uint64
+	inflight                     atomic.Int64
+}
+
+func newLoadGuard[K comparable, V any](cfg Config[K, V], clock Clock, log *slog.Logger) *loadGuard {
+	if cfg.MaxConcurrentLoads <= 0 && cfg.LoadRate <= 0 && cfg.BreakerFailures <= 0 {
+		return nil
+	}
+
+	if log == nil {
+		log = discard
+	}
+	g := &loadGuard{}
+	if cfg.MaxConcurrentLoads > 0 {
+		g.sem = make(chan struct{}, cfg.MaxConcurrentLoads)
+	}
+	if cfg.LoadRate > 0 {
+		burst := float64(cfg.LoadBurst)
+		if burst <= 0 {
+			burst = math.Ceil(cfg.LoadRate)
+		}
+		g.bucket = &tokenBucket{clock: clock, rate: cfg.LoadRate, burst: burst, tokens: burst, last: clock.Now()}
+	}
+	if cfg.BreakerFailures > 0 {
+		cooldown := cfg.BreakerCooldown
+		if cooldown <= 0 {
+			cooldown = defaultBreakerCooldown
+		}
+		g.breaker = &breaker{clock: clock, threshold: cfg.BreakerFailures, cooldown: cooldown, log: log}
+	}
+	return g
+}
+
+// acquire 获得一次访问数据源的许可，成功时返回的 release 必须用加载的结果调用一次
+func (g *loadGuard) acquire(ctx context.Context) (release func(err error), err error) {
+	if g == nil {
+		return func(error) {}, nil
+	}
+
+	if g.bucket != nil && !g.bucket.allow() {
+		g.rateLimited.Add(1)
+		return nil, ErrRateLimited
+	}
+	var ticket uint64
+	if g.breaker != nil {
+		var ok bool
+		if ticket, ok = g.breaker.allow(); !ok {
+			g.circuitRejected.Add(1)
+			return nil, ErrCircuitOpen
+		}
+	}
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-ctx.Done():
+			// 没有访问数据源，不计入熔断
+			g.breaker.abort(ticket)
+			return nil, ctx.Err()
+		}
+	}
+
+	g.inflight.Add(1)
+	return func(err error) {
+		g.inflight.Add(-1)
+		if g.sem != nil {
+			<-g.sem
+		}
+		g.breaker.report(ticket, err)
+	}, nil
+}
+
+// retaining 在熔断器没有关闭时返回 true，期间保留过期数据用于兜底
+func (g *loadGuard) retaining() bool {
+	return g != nil && g.breaker != nil && g.breaker.tripped.Load()
+}
+
+func (g *loadGuard) stats(s *Stats) {
+	if g == nil {
+		return
+	}
+	s.RateLimited = g.rateLimited.Load()
+	s.CircuitRejected = g.circuitRejected.Load()
+	s.InflightLoads = g.inflight.Load()
+	s.Breaker = g.breaker.state()
+	s.BreakerOpens = g.breaker.openCount()
+}
+
+// tokenBucket 是令牌桶，按 rate 匀速补充令牌，最多积累 burst 个
+type tokenBucket struct {
+	clock  Clock
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// breaker 是熔断器，方法可以在 nil 上调用
+type breaker struct {
+	clock     Clock
+	threshold int
+	cooldown  time.Duration
+	log       *slog.Logger
+
+	mu       sync.Mutex
+	st       BreakerState
+	failures int
+	openedAt time.Time
+	// gen 在每次状态变化和放行试探时加 1，只有 ticket 等于 gen 的加载结果会改变状态，
+	// 状态变化之前放行的慢加载不会干扰之后的试探
+	gen uint64
+	// 半开状态下是否已经放行了试探的加载
+	probing bool
+	opens   uint64
+	// tripped 在熔断器没有关闭时为 true，查询数据时读取，不需要加锁
+	tripped atomic.Bool
+}
+
+// allow 判断是否放行一次加载，放行时返回的 ticket 需要传给 report 或 abort
+func (b *breaker) allow() (ticket uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.st {
+	case BreakerOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			return 0, false
+		}
+		b.st = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probing {
+			return 0, false
+		}
+		b.probing = true
+		b.gen++
+	}
+	return b.gen, true
+}
+
+// report 记录一次加载的结果，ErrNotFound 表示数据源正常，调用方取消不计入。
+// 熔断器在放行之后已经变化过状态时，结果只属于过去的状态，忽略
+func (b *breaker) report(ticket uint64, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ticket != b.gen {
+		return
+	}
+	probe := b.st == BreakerHalfOpen
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if err == nil || errors.Is(err, ErrNotFound) {
+		if b.st != BreakerClosed {
+			b.log.Info("cache circuit breaker closed")
+			b.gen++
+		}
+		b.st, b.failures = BreakerClosed, 0
+		b.tripped.Store(false)
+		return
+	}
+
+	b.failures++
+	if probe || b.failures >= b.threshold {
+		b.st, b.openedAt = BreakerOpen, b.clock.Now()
+		b.gen++
+		b.opens++
+		b.tripped.Store(true)
+		b.log.Warn("cache circuit breaker opened", "failures", b.failures, "cooldown", b.cooldown, "error", err)
+	}
+}
+
+// abort 放弃已经放行的加载，放弃的是试探时允许下一次试探
+func (b *breaker) abort(ticket uint64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ticket == b.gen {
+		b.probing = false
+	}
+}
+
+// state 返回用于统计的状态，冷却结束但还没有试探时也算作半开
+func (b *breaker) state() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.st == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.st
+}
+
+func (b *breaker) openCount() uint64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.opens
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errSource = errors.New("source unavailable")
+
+func TestTokenBucket(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	g := newLoadGuard(Config[string, int]{LoadRate: 2, LoadBurst: 3}, clock, nil)
+	ctx := context.Background()
+
+	allowed := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			release, err := g.acquire(ctx)
+			if errors.Is(err, ErrRateLimited) {
+				break
+			}
+			release(nil)
+			n++
+		}
+		return n
+	}
+
+	if n := allowed(); n != 3 {
+		t.Fatalf("allowed %d loads from a full bucket, want the burst of 3", n)
+	}
+	clock.Add(500 * time.Millisecond)
+	if n := allowed(); n != 1 {
+		t.Fatalf("allowed %d loads after 0.5s at 2/s, want 1", n)
+	}
+	// 空闲再久也只能积累 burst 个令牌
+	clock.Add(time.Hour)
+	if n := allowed(); n != 3 {
+		t.Fatalf("allowed %d loads after a long idle period, want the burst of 3", n)
+	}
+
+	var stats Stats
+	g.stats(&stats)
+	if stats.RateLimited != 3 {
+		t.Fatalf("RateLimited = %d, want 3", stats.RateLimited)
+	}
+}
+
+func TestBreakerStateMachine(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	b := &breaker{clock: clock, threshold: 2, cooldown: 5 * time.Second, log: discard}
+
+	mustAllow := func() uint64 {
+		t.Helper()
+		ticket, ok := b.allow()
+		if !ok {
+			t.Fatalf("rejected in state %v", b.state())
+		}
+		return ticket
+	}
+	wantState := func(want BreakerState) {
+		t.Helper()
+		if got := b.state(); got != want {
+			t.Fatalf("state = %v, want %v", got, want)
+		}
+	}
+
+	// 成功会清零连续失败的计数
+	b.report(mustAllow(), errSource)
+	b.report(mustAllow(), nil)
+	b.report(mustAllow(), errSource)
+	wantState(BreakerClosed)
+
+	// 慢加载在熔断之前放行
+	slow := mustAllow()
+	b.report(mustAllow(), errSource)
+	wantState(BreakerOpen)
+	if _, ok := b.allow(); ok {
+		t.Fatal("allowed a load while open")
+	}
+
+	// 冷却结束后只放行一次试探
+	clock.Add(5 * time.Second)
+	wantState(BreakerHalfOpen)
+	probe := mustAllow()
+	if _, ok := b.allow(); ok {
+		t.Fatal("allowed a second probe")
+	}
+
+	// 慢加载的结果不能清除试探，也不能关闭熔断器
+	b.report(slow, nil)
+	wantState(BreakerHalfOpen)
+	if _, ok := b.allow(); ok {
+		t.Fatal("stale report let another probe through")
+	}
+
+	// 试探失败重新打开
+	b.report(probe, errSource)
+	wantState(BreakerOpen)
+	if b.openCount() != 2 {
+		t.Fatalf("opens = %d, want 2", b.openCount())
+	}
+
+	// 放弃的试探允许下一次试探
+	clock.Add(5 * time.Second)
+	b.abort(mustAllow())
+	probe = mustAllow()
+
+	// 试探成功关闭熔断器，之前的试探 ticket 不再有效
+	b.report(probe, ErrNotFound)
+	wantState(BreakerClosed)
+	if b.tripped.Load() {
+		t.Fatal("tripped after the breaker closed")
+	}
+	b.report(probe, errSource)
+	b.report(mustAllow(), errSource)
+	wantState(BreakerClosed)
+}
+
+func TestLoadGuardSemaphore(t *testing.T) {
+	g := newLoadGuard(Config[string, int]{MaxConcurrentLoads: 2}, NewFakeClock(epoch), nil)
+	ctx := context.Background()
+
+	first, err := g.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := g.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := g.acquire(canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire beyond the limit = %v, want the ctx error", err)
+	}
+	var stats Stats
+	g.stats(&stats)
+	if stats.InflightLoads != 2 {
+		t.Fatalf("InflightLoads = %d, want 2", stats.InflightLoads)
+	}
+
+	first(nil)
+	third, err := g.acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire after release = %v", err)
+	}
+	second(nil)
+	third(nil)
+	g.stats(&stats)
+	if stats.InflightLoads != 0 {
+		t.Fatalf("InflightLoads = %d after all releases, want 0", stats.InflightLoads)
+	}
+}
@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHotKeyThreshold = 10
+	// 没有设置 RefreshAhead 时热点 key 提前刷新的比例
+	defaultHotRefreshAhead = 0.2
+	cmsDepth               = 4
+	// 每个 Top-K 位置对应的计数器列数，列越多误差越小
+	cmsWidthPerKey = 256
+	// 记录 width*cmsWindow 次之后所有计数减半
+	cmsWindow = 10
+	// hotKeys 按 key 的哈希分成多段，每段有自己的锁，并发读取不同的 key 时不会互相等待
+	hotKeyStripes = 8
+	// 每段每记录这么多次检查一次是否需要减半，并重新计算全局 Top-K 的下界，必须是 2 的幂
+	hotKeyCheckInterval = 4096
+)
+
+// HotKey 是一个热点 key 和它在当前统计窗口内估算的访问次数
+type HotKey[K comparable] struct {
+	Key  K
+	Hits uint32
+}
+
+// countMinSketch 估算 key 的访问次数，只会高估不会低估
+type countMinSketch struct {
+	counters []uint32
+	mask     uint64
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	width = 1 << bits.Len(uint(width-1))
+	return &countMinSketch{
+		counters: make([]uint32, cmsDepth*width),
+		mask:     uint64(width - 1),
+	}
+}
+
+// index 用双重哈希计算第 row 行的计数器位置
+func (s *countMinSketch) index(hash uint64, row int) int {
+	h1, h2 := hash&0xffffffff, hash>>32|1
+	return row*int(s.mask+1) + int((h1+uint64(row)*h2)&s.mask)
+}
+
+// add 使用保守更新，只增加等于最小值的计数器，返回新的估算值
+func (s *countMinSketch) add(hash uint64) uint32 {
+	estimate := s.estimate(hash) + 1
+	for row := 0; row < cmsDepth; row++ {
+		i := s.index(hash, row)
+		if s.counters[i] < estimate {
+			s.counters[i] = estimate
+		}
+	}
+	return estimate
+}
+
+func (s *countMinSketch) estimate(hash uint64) uint32 {
+	n := ^uint32(0)
+	for row := 0; row < cmsDepth; row++ {
+		n = min(n, s.counters[s.index(hash, row)])
+	}
+	return n
+}
+
+// halve 把所有计数减半，过去的热点会逐渐冷却
+func (s *countMinSketch) halve() {
+	for i := range s.counters {
+		s.counters[i] >>= 1
+	}
+}
+
+// hotKeys 用 Count-Min Sketch 估算访问次数，并维护估算值最大的 size 个 key，方法可以在 nil 上调用。
+// 每个 key 只落在一段中，每段各自维护 size 个候选，合并后就是全局的 Top-K。
+// 所有段一共记录 width*cmsWindow 次之后一起减半，访问集中在某一段时各段的计数仍然可以比较
+type hotKeys[K comparable] struct {
+	stripes   [hotKeyStripes]*hotStripe[K]
+	size      int
+	threshold uint32
+	// cutoff 是最近一次合并时全局第 size 名的访问次数，低于它的候选不在 Top-K 中
+	cutoff atomic.Uint32
+
+	// 同一时间只有一个 goroutine 检查减半，nextHalve 是下一次减半时所有段的记录总数
+	maintaining sync.Mutex
+	window      uint64
+	nextHalve   uint64
+}
+
+type hotStripe[K comparable] struct {
+	mu     sync.Mutex
+	sketch *countMinSketch
+	top    map[K]uint32
+	// floor 不大于 top 中的最小值，访问次数不超过它的 key 不需要扫描 top
+	floor uint32
+	adds  uint64
+}
+
+func newHotKeys[K comparable](size, threshold int) *hotKeys[K] {
+	if size <= 0 {
+		return nil
+	}
+	if threshold <= 0 {
+		threshold = defaultHotKeyThreshold
+	}
+	width := max(size*cmsWidthPerKey, 1024)
+	h := &hotKeys[K]{
+		size:      size,
+		threshold: uint32(threshold),
+		window:    uint64(width * cmsWindow),
+		nextHalve: uint64(width * cmsWindow),
+	}
+	for i := range h.stripes {
+		h.stripes[i] = &hotStripe[K]{
+			sketch: newCountMinSketch(width / hotKeyStripes),
+			top:    make(map[K]uint32),
+		}
+	}
+	return h
+}
+
+// stripe 返回 key 所在的段和用于 sketch 的哈希
+func (h *hotKeys[K]) stripe(key K) (*hotStripe[K], uint64) {
+	// 分片缓存按 hashKey 选择分片，同一个分片的 key 哈希的低位相同，需要再混淆一次
+	hash := mix64(hashKey(key))
+	// sketch 使用哈希的低 32 位和高 32 位，用中间的位选择段
+	return h.stripes[hash>>29%hotKeyStripes], hash
+}
+
+// record 记录一次访问，只锁 key 所在的段
+func (h *hotKeys[K]) record(key K) {
+	if h == nil {
+		return
+	}
+	s, hash := h.stripe(key)
+
+	s.mu.Lock()
+	s.add(key, hash, h.size)
+	s.adds++
+	check := s.adds&(hotKeyCheckInterval-1) == 0
+	s.mu.Unlock()
+
+	// 减半和合并时依次获取每一段的锁，不能持有本段的锁
+	if check {
+		h.maintain()
+	}
+}
+
+// maintain 在所有段的记录总数达到 nextHalve 时减半，并更新 cutoff，其他 goroutine 正在执行时直接返回
+func (h *hotKeys[K]) maintain() {
+	if !h.maintaining.TryLock() {
+		return
+	}
+	defer h.maintaining.Unlock()
+
+	top, adds := h.merge()
+	if adds >= h.nextHalve {
+		h.halve()
+		h.nextHalve = adds + h.window
+		top, _ = h.merge()
+	}
+
+	var cutoff uint32
+	if len(top) == h.size {
+		cutoff = top[len(top)-1].Hits
+	}
+	h.cutoff.Store(cutoff)
+}
+
+// add 记录一次访问并更新本段的候选，调用方持有锁
+func (s *hotStripe[K]) add(key K, hash uint64, size int) {
+	n := s.sketch.add(hash)
+	if _, ok := s.top[key]; ok || len(s.top) < size {
+		s.top[key] = n
+		return
+	}
+	if n <= s.floor {
+		return
+	}
+
+	// 找出最小的两个，替换最小的之后第二小的就是新的下界
+	var victim K
+	lowest, second := ^uint32(0), ^uint32(0)
+	for k, hits := range s.top {
+		switch {
+		case hits < lowest:
+			victim, lowest, second = k, hits, lowest
+		case hits < second:
+			second = hits
+		}
+	}
+	if n <= lowest {
+		s.floor = lowest
+		return
+	}
+	delete(s.top, victim)
+	s.top[key] = n
+	s.floor = min(second, n)
+}
+
+// halve 把所有段的计数减半
+func (h *hotKeys[K]) halve() {
+	for _, s := range h.stripes {
+		s.mu.Lock()
+		s.sketch.halve()
+		for k, hits := range s.top {
+			if hits >>= 1; hits == 0 {
+				delete(s.top, k)
+			} else {
+				s.top[k] = hits
+			}
+		}
+		s.floor >>= 1
+		s.mu.Unlock()
+	}
+}
+
+// isHot 判断 key 是否在 Top-K 中并且访问次数达到阈值
+func (h *hotKeys[K]) isHot(key K) bool {
+	if h == nil {
+		return false
+	}
+	s, _ := h.stripe(key)
+
+	s.mu.Lock()
+	hits := s.top[key]
+	s.mu.Unlock()
+
+	return hits >= h.threshold && hits >= h.cutoff.Load()
+}
+
+// merge 合并所有段的候选，返回全局的 Top-K，按访问次数从多到少排序，以及所有段的记录总数
+func (h *hotKeys[K]) merge() (top []HotKey[K], adds uint64) {
+	for _, s := range h.stripes {
+		s.mu.Lock()
+		for k, hits := range s.top {
+			top = append(top, HotKey[K]{k, hits})
+		}
+		adds += s.adds
+		s.mu.Unlock()
+	}
+	sortHotKeys(top)
+	if len(top) > h.size {
+		top = top[:h.size]
+	}
+	return top, adds
+}
+
+// report 返回访问次数达到阈值的热点 key，按访问次数从多到少排序
+func (h *hotKeys[K]) report() []HotKey[K] {
+	if h == nil {
+		return nil
+	}
+
+	top, _ := h.merge()
+	n := sort.Search(len(top), func(i int) bool { return top[i].Hits < h.threshold })
+	return top[:n]
+}
+
+func sortHotKeys[K comparable](hot []HotKey[K]) {
+	sort.Slice(hot, func(i, j int) bool { return hot[i].Hits > hot[j].Hits })
+}
+
+// HotKeyReport 返回当前的热点 key，按估算的访问次数从多到少排序，没有开启 HotKeys 时返回空
+func (c *LocalCache[K, V]) HotKeyReport() []HotKey[K] {
+	return c.hot.report()
+}
+
+// hotTTL 让热点 key 的 TTL 至少为 HotKeyTTL
+func (c *LocalCache[K, V]) hotTTL(key K, ttl time.Duration) time.Duration {
+	if c.cfg.HotKeyTTL <= ttl || !c.hot.isHot(key) {
+		return ttl
+	}
+	return c.cfg.HotKeyTTL
+}
+
+// pinned 判断容量淘汰时是否跳过 key
+func (c *LocalCache[K, V]) pinned(key K) bool {
+	return c.cfg.PinHotKeys && c.hot.isHot(key)
+}
+
+// HotKeyReport 汇总所有分片的热点 key，最多返回 HotKeys 个
+func (c *ShardedCache[K, V]) HotKeyReport() []HotKey[K] {
+	var hot []HotKey[K]
+	for _, shard := range c.shards {
+		hot = append(hot, shard.HotKeyReport()...)
+	}
+	sortHotKeys(hot)
+	if len(hot) > c.cfg.HotKeys {
+		hot = hot[:c.cfg.HotKeys]
+	}
+	return hot
+}
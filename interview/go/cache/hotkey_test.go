@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHotKeysTopK(t *testing.T) {
+	const size = 4
+	h := newHotKeys[string](size, 10)
+
+	// hot-i 访问 (i+1)*100 次，冷 key 各访问一次
+	for round := 0; round < 800; round++ {
+		for i := 0; i < 8; i++ {
+			if round < (i+1)*100 {
+				h.record(fmt.Sprintf("hot-%d", i))
+			}
+		}
+		h.record(fmt.Sprintf("cold-%d", round))
+	}
+	h.maintain()
+
+	report := h.report()
+	if len(report) != size {
+		t.Fatalf("report has %d keys, want %d: %v", len(report), size, report)
+	}
+	for i, hk := range report {
+		if want := fmt.Sprintf("hot-%d", 7-i); hk.Key != want {
+			t.Errorf("report[%d] = %s, want %s", i, hk.Key, want)
+		}
+	}
+
+	hot := 0
+	for i := 0; i < 8; i++ {
+		if h.isHot(fmt.Sprintf("hot-%d", i)) {
+			hot++
+		}
+	}
+	if hot != size {
+		t.Errorf("%d keys are hot, want %d", hot, size)
+	}
+	if h.isHot("cold-0") {
+		t.Error("cold key is hot")
+	}
+}
@@ -0,0 +1,45 @@
+// The demo is for detecting hot keys
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// 模拟访问服从 Zipf 分布：少数 key 占了大部分访问，探测出的热点 key 延长 TTL，
+// 并且不会因为容量被淘汰
+func SimulateHotKeys() {
+	clock := NewFakeClock(time.Now())
+	cache := NewLocalCache(Config[string, string]{
+		Clock:      clock,
+		MaxEntries: 100,
+		HotKeys:    5,
+		HotKeyTTL:  time.Minute,
+		PinHotKeys: true,
+		Logger:     logger,
+	})
+	queryFromDB := func(ctx context.Context, key string) (string, error) {
+		return "Data for " + key, nil
+	}
+
+	zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.2, 1, 999)
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("key-%d", zipf.Uint64())
+		cache.GetOrLoad(context.Background(), key, time.Second, queryFromDB)
+	}
+
+	for _, hot := range cache.HotKeyReport() {
+		_, found := cache.Get(hot.Key)
+		logger.Info("hot key", "key", hot.Key, "hits", hot.Hits, "cached", found)
+	}
+
+	// 热点 key 写入时 TTL 至少为 HotKeyTTL，1 秒后仍然有效
+	cache.Set("key-0", "Hot Data", time.Second)
+	clock.Add(1100 * time.Millisecond)
+	_, found := cache.Get("key-0")
+	stats := cache.Stats()
+	logger.Info("after ttl", "key", "key-0", "cached", found, "evictions", stats.Evictions, "loads", stats.Loads)
+}
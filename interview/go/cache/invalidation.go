@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// 失效消息编码后的最大长度，超过 UDP 单个报文的大小
+	maxInvalidationSize = 64 << 10
+
+	invalidationBackoff    = 100 * time.Millisecond
+	maxInvalidationBackoff = 5 * time.Second
+
+	// 每个订阅同时在后台刷新的最大 key 数量
+	maxInvalidationRefreshes = 16
+)
+
+// Invalidation 是一条失效消息，Source 是发布者的 ID
+type Invalidation struct {
+	Source string
+	Key    string
+}
+
+// InvalidationTransport 在实例之间传递失效消息，Publish 发送的消息所有连接到同一个传输的实例都能收到，
+// 包括自己。Receive 阻塞到收到消息，Close 之后返回 net.ErrClosed
+type InvalidationTransport interface {
+	Publish(ctx context.Context, msg Invalidation) error
+	Receive() (Invalidation, error)
+	Close() error
+}
+
+// InvalidationBus 把本实例修改过的 key 发布给其他实例，并把其他实例发布的 key 交给订阅者，
+// 自己发布的消息不会交给自己的订阅者，所以每个实例应该使用自己的 InvalidationBus
+type InvalidationBus struct {
+	id        string
+	transport InvalidationTransport
+	log       *slog.Logger
+
+	mu   sync.RWMutex
+	subs map[uint64]func(key string)
+	next uint64
+
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// 创建失效消息总线并开始接收消息，logger 为空时不输出，使用完需要调用 Close
+func NewInvalidationBus(transport InvalidationTransport, logger *slog.Logger) *InvalidationBus {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	b := &InvalidationBus{
+		id:        hex.EncodeToString(id),
+		transport: transport,
+		log:       logger,
+		subs:      make(map[uint64]func(key string)),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if b.log == nil {
+		b.log = discard
+	}
+	go b.run()
+	return b
+}
+
+// Publish 通知其他实例 key 已经失效
+func (b *InvalidationBus) Publish(ctx context.Context, key string) error {
+	return b.transport.Publish(ctx, Invalidation{Source: b.id, Key: key})
+}
+
+// Subscribe 注册收到其他实例的失效消息时的回调，回调在接收消息的 goroutine 中依次执行，不能阻塞
+func (b *InvalidationBus) Subscribe(fn func(key string)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+func (b *InvalidationBus) run() {
+	defer close(b.done)
+
+	backoff := invalidationBackoff
+	for {
+		msg, err := b.transport.Receive()
+		if err != nil {
+			select {
+			case <-b.closing:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			// 连接断开时传输会在下一次 Receive 时重连，按指数退避避免空转
+			b.log.Warn("cache invalidation receive failed", "error", err, "retry", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-b.closing:
+				return
+			}
+			backoff = min(backoff*2, maxInvalidationBackoff)
+			continue
+		}
+		backoff = invalidationBackoff
+
+		if msg.Source == b.id {
+			continue
+		}
+		b.log.Debug("cache invalidation received", "key", msg.Key, "source", msg.Source)
+
+		b.mu.RLock()
+		for _, fn := range b.subs {
+			fn(msg.Key)
+		}
+		b.mu.RUnlock()
+	}
+}
+
+// Close 停止接收消息并关闭传输
+func (b *InvalidationBus) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.closing)
+		err = b.transport.Close()
+		<-b.done
+	})
+	return err
+}
+
+// SubscribeInvalidations 让缓存订阅总线上 prefix 开头的 key，收到后删除本地数据。
+// 缓存实现了 Invalidate（例如 TieredCache 和 LocalCache）时只删除本地数据，不会修改后端存储，refresh 为 true 时
+// 删除后在后台调用 Get 从共享的二级缓存读取新值，同时刷新的 key 超过 16 个时跳过刷新，下次访问时再读取；
+// 否则调用 Delete 删除数据。
+// 非 string 的 key 按 fmt.Sprint 的格式发布，收到后用 fmt.Sscan 解析
+func SubscribeInvalidations[K comparable, V any](bus *InvalidationBus, c Cache[K, V], prefix string, refresh bool) (unsubscribe func()) {
+	drop := c.Delete
+	if inv, ok := any(c).(interface{ Invalidate(key K) }); ok {
+		drop = inv.Invalidate
+	}
+	// 刷新会访问 L2，不能阻塞接收消息的 goroutine
+	refreshing := make(chan struct{}, maxInvalidationRefreshes)
+
+	return bus.Subscribe(func(s string) {
+		if len(s) < len(prefix) || s[:len(prefix)] != prefix {
+			return
+		}
+		key, err := parseKey[K](s[len(prefix):])
+		if err != nil {
+			bus.log.Warn("cache invalidation key invalid", "key", s, "error", err)
+			return
+		}
+
+		drop(key)
+		if !refresh {
+			return
+		}
+		select {
+		case refreshing <- struct{}{}:
+			go func() {
+				defer func() { <-refreshing }()
+				c.Get(key)
+			}()
+		default:
+			bus.log.Debug("cache invalidation refresh skipped", "key", s)
+		}
+	})
+}
+
+// parseKey 是 keyString 的逆操作
+func parseKey[K comparable](s string) (K, error) {
+	var key K
+	if p, ok := any(&key).(*string); ok {
+		*p = s
+		return key, nil
+	}
+	_, err := fmt.Sscan(s, &key)
+	return key, err
+}
+
+// encodeInvalidation 把失效消息编码为两段带 uvarint 长度前缀的字符串
+func encodeInvalidation(msg Invalidation) ([]byte, error) {
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64+len(msg.Source)+len(msg.Key))
+	buf = binary.AppendUvarint(buf, uint64(len(msg.Source)))
+	buf = append(buf, msg.Source...)
+	buf = binary.AppendUvarint(buf, uint64(len(msg.Key)))
+	buf = append(buf, msg.Key...)
+	if len(buf) > maxInvalidationSize {
+		return nil, fmt.Errorf("cache: invalidation of %d bytes exceeds %d", len(buf), maxInvalidationSize)
+	}
+	return buf, nil
+}
+
+func decodeInvalidation(data []byte) (Invalidation, error) {
+	var fields [2]string
+	for i := range fields {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || n > uint64(len(data)-size) {
+			return Invalidation{}, errors.New("cache: malformed invalidation")
+		}
+		data = data[size:]
+		fields[i] = string(data[:n])
+		data = data[n:]
+	}
+	if len(data) != 0 {
+		return Invalidation{}, errors.New("cache: malformed invalidation")
+	}
+	return Invalidation{Source: fields[0], Key: fields[1]}, nil
+}
+
+const defaultChannelBuffer = 256
+
+// ChannelBroker 在同一个进程内传递失效消息，用于测试或者一个进程里运行多个实例
+type ChannelBroker struct {
+	mu      sync.RWMutex
+	members map[*channelTransport]struct{}
+}
+
+// 创建进程内的消息代理
+func NewChannelBroker() *ChannelBroker {
+	return &ChannelBroker{members: make(map[*channelTransport]struct{})}
+}
+
+// Transport 创建一个连接到代理的传输，每个 InvalidationBus 使用一个
+func (b *ChannelBroker) Transport() InvalidationTransport {
+	t := &channelTransport{
+		broker: b,
+		msgs:   make(chan Invalidation, defaultChannelBuffer),
+		closed: make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.members[t] = struct{}{}
+	b.mu.Unlock()
+	return t
+}
+
+type channelTransport struct {
+	broker *ChannelBroker
+	msgs   chan Invalidation
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Publish 把消息放入每个成员的缓冲区，缓冲区满时等待，直到 ctx 结束
+func (t *channelTransport) Publish(ctx context.Context, msg Invalidation) error {
+	t.broker.mu.RLock()
+	defer t.broker.mu.RUnlock()
+
+	for m := range t.broker.members {
+		select {
+		case m.msgs <- msg:
+		case <-m.closed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (t *channelTransport) Receive() (Invalidation, error) {
+	select {
+	case msg := <-t.msgs:
+		return msg, nil
+	case <-t.closed:
+		return Invalidation{}, net.ErrClosed
+	}
+}
+
+func (t *channelTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+		t.broker.mu.Lock()
+		delete(t.broker.members, t)
+		t.broker.mu.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// 转发消息时单个连接的写超时，超时的慢连接会被断开
+const hubWriteTimeout = time.Second
+
+// writeFrame 按 4 字节大端长度加消息体的格式写入一帧，解决 TCP 粘包
+func writeFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame 读取 writeFrame 写入的一帧，长度超过 maxSize 时返回错误
+func readFrame(r io.Reader, maxSize int) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length > uint32(maxSize) {
+		return nil, fmt.Errorf("cache: frame of %d bytes exceeds %d", length, maxSize)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// InvalidationHub 是 TCP 失效消息的中转服务，把每个连接发来的帧转发给所有连接
+type InvalidationHub struct {
+	ln net.Listener
+
+	mu     sync.Mutex
+	conns  map[*hubConn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type hubConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// 在 addr 上启动中转服务
+func NewInvalidationHub(addr string) (*InvalidationHub, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &InvalidationHub{
+		ln:    ln,
+		conns: make(map[*hubConn]struct{}),
+	}
+	h.wg.Add(1)
+	go h.serve()
+	return h, nil
+}
+
+// Addr 返回服务监听的地址
+func (h *InvalidationHub) Addr() string {
+	return h.ln.Addr().String()
+}
+
+func (h *InvalidationHub) serve() {
+	defer h.wg.Done()
+
+	for {
+		conn, err := h.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		hc := &hubConn{conn: conn}
+		h.mu.Lock()
+		if h.closed {
+			h.mu.Unlock()
+			conn.Close()
+			return
+		}
+		h.conns[hc] = struct{}{}
+		h.wg.Add(1)
+		h.mu.Unlock()
+
+		go h.handle(hc)
+	}
+}
+
+func (h *InvalidationHub) handle(hc *hubConn) {
+	defer h.wg.Done()
+	defer h.drop(hc)
+
+	r := bufio.NewReader(hc.conn)
+	for {
+		payload, err := readFrame(r, maxInvalidationSize)
+		if err != nil {
+			return
+		}
+		h.broadcast(payload)
+	}
+}
+
+func (h *InvalidationHub) broadcast(payload []byte) {
+	h.mu.Lock()
+	conns := make([]*hubConn, 0, len(h.conns))
+	for hc := range h.conns {
+		conns = append(conns, hc)
+	}
+	h.mu.Unlock()
+
+	for _, hc := range conns {
+		hc.mu.Lock()
+		hc.conn.SetWriteDeadline(time.Now().Add(hubWriteTimeout))
+		err := writeFrame(hc.conn, payload)
+		hc.mu.Unlock()
+		if err != nil {
+			// 关闭连接后 handle 的读取会失败并移除连接
+			hc.conn.Close()
+		}
+	}
+}
+
+func (h *InvalidationHub) drop(hc *hubConn) {
+	h.mu.Lock()
+	delete(h.conns, hc)
+	h.mu.Unlock()
+	hc.conn.Close()
+}
+
+// Close 停止监听并断开所有连接
+func (h *InvalidationHub) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	err := h.ln.Close()
+	for hc := range h.conns {
+		hc.conn.Close()
+	}
+	h.mu.Unlock()
+
+	h.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// tcpTransport 连接到 InvalidationHub，连接断开后在下一次 Publish 或 Receive 时重连，
+// 断开期间的消息会丢失
+type tcpTransport struct {
+	addr   string
+	dialer net.Dialer
+
+	mu     sync.Mutex
+	conn   net.Conn
+	r      *bufio.Reader
+	closed bool
+	// 写入同一个连接的帧不能交错
+	writeMu sync.Mutex
+}
+
+// NewTCPTransport 创建连接到 InvalidationHub 的传输，先同步建立一次连接
+func NewTCPTransport(ctx context.Context, addr string) (InvalidationTransport, error) {
+	t := &tcpTransport{addr: addr}
+	if _, _, err := t.connect(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tcpTransport) connect(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, nil, net.ErrClosed
+	}
+	if t.conn != nil {
+		return t.conn, t.r, nil
+	}
+
+	conn, err := t.dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.conn, t.r = conn, bufio.NewReader(conn)
+	return t.conn, t.r, nil
+}
+
+// reset 关闭出错的连接，连接已经被替换时不做处理
+func (t *tcpTransport) reset(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == conn {
+		t.conn, t.r = nil, nil
+	}
+	conn.Close()
+}
+
+func (t *tcpTransport) Publish(ctx context.Context, msg Invalidation) error {
+	payload, err := encodeInvalidation(msg)
+	if err != nil {
+		return err
+	}
+	conn, _, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	if err := writeFrame(conn, payload); err != nil {
+		t.reset(conn)
+		return err
+	}
+	return nil
+}
+
+func (t *tcpTransport) Receive() (Invalidation, error) {
+	conn, r, err := t.connect(context.Background())
+	if err != nil {
+		return Invalidation{}, err
+	}
+
+	payload, err := readFrame(r, maxInvalidationSize)
+	if err != nil {
+		t.reset(conn)
+		t.mu.Lock()
+		closed := t.closed
+		t.mu.Unlock()
+		if closed {
+			return Invalidation{}, net.ErrClosed
+		}
+		return Invalidation{}, err
+	}
+	return decodeInvalidation(payload)
+}
+
+func (t *tcpTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	if t.conn != nil {
+		return t.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// subscribeKeys 把总线收到的 key 放入 channel
+func subscribeKeys(bus *InvalidationBus) <-chan string {
+	keys := make(chan string, 64)
+	bus.Subscribe(func(key string) { keys <- key })
+	return keys
+}
+
+func nextKey(t *testing.T, keys <-chan string) string {
+	t.Helper()
+	select {
+	case key := <-keys:
+		return key
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invalidation received")
+		return ""
+	}
+}
+
+func TestChannelInvalidationBus(t *testing.T) {
+	ctx := context.Background()
+	broker := NewChannelBroker()
+	a := NewInvalidationBus(broker.Transport(), nil)
+	defer a.Close()
+	b := NewInvalidationBus(broker.Transport(), nil)
+	defer b.Close()
+
+	cache := NewLocalCache(Config[int, string]{})
+	defer cache.Close()
+	cache.Set(1, "one", time.Hour)
+	cache.Set(2, "two", time.Hour)
+	SubscribeInvalidations[int, string](b, cache, "user:", false)
+
+	keysA, keysB := subscribeKeys(a), subscribeKeys(b)
+	for _, key := range []string{"user:1", "order:2", "user:x", "done"} {
+		if err := a.Publish(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 同一个总线的回调按消息顺序执行，收到 done 时前面的消息都已经处理完
+	for _, want := range []string{"user:1", "order:2", "user:x", "done"} {
+		if got := nextKey(t, keysB); got != want {
+			t.Fatalf("b received %q, want %q", got, want)
+		}
+	}
+	if _, found := cache.Get(1); found {
+		t.Fatal("user:1 was not invalidated")
+	}
+	if _, found := cache.Get(2); !found {
+		t.Fatal("key outside the prefix was invalidated")
+	}
+
+	// a 不会收到自己发布的消息，第一条收到的是 b 发布的
+	b.Publish(ctx, "from b")
+	if got := nextKey(t, keysA); got != "from b" {
+		t.Fatalf("a received %q, want only messages from b", got)
+	}
+
+	// 关闭后的成员不再接收，也不会阻塞其他成员发布
+	b.Close()
+	for i := 0; i < defaultChannelBuffer+1; i++ {
+		if err := a.Publish(ctx, "after close"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTCPInvalidationHub(t *testing.T) {
+	ctx := context.Background()
+	hub, err := NewInvalidationHub("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+
+	connect := func() *InvalidationBus {
+		transport, err := NewTCPTransport(ctx, hub.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus := NewInvalidationBus(transport, nil)
+		t.Cleanup(func() { bus.Close() })
+		return bus
+	}
+	a, b := connect(), connect()
+	keysB := subscribeKeys(b)
+
+	// 中转服务异步接受连接，b 注册之前发布的消息会丢失，重复发布直到 b 收到
+	deadline := time.Now().Add(5 * time.Second)
+	for received := false; !received; {
+		if time.Now().After(deadline) {
+			t.Fatal("hub never forwarded to b")
+		}
+		a.Publish(ctx, "hello")
+		select {
+		case <-keysB:
+			received = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for len(keysB) > 0 {
+		<-keysB
+	}
+
+	// 连接建立后消息按发布的顺序到达，包括超过一个 TCP 报文的长 key
+	long := string(bytes.Repeat([]byte("k"), 16<<10))
+	want := []string{"user:1", long, "user:2"}
+	for _, key := range want {
+		if err := a.Publish(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range want {
+		if got := nextKey(t, keysB); got != key {
+			t.Fatalf("b received %.20q, want %.20q", got, key)
+		}
+	}
+
+	if err := a.Publish(ctx, string(make([]byte, maxInvalidationSize))); err == nil {
+		t.Fatal("Publish accepted an oversized invalidation")
+	}
+}
+
+func TestInvalidationEncoding(t *testing.T) {
+	msg := Invalidation{Source: "abc", Key: "user:1"}
+	data, err := encodeInvalidation(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decodeInvalidation(data); err != nil || got != msg {
+		t.Fatalf("decodeInvalidation = %+v, %v, want %+v", got, err, msg)
+	}
+
+	for n := 0; n < len(data); n++ {
+		if _, err := decodeInvalidation(data[:n]); err == nil {
+			t.Fatalf("decodeInvalidation accepted %d of %d bytes", n, len(data))
+		}
+	}
+	if _, err := decodeInvalidation(append(data, 0)); err == nil {
+		t.Fatal("decodeInvalidation accepted trailing bytes")
+	}
+
+	var buf bytes.Buffer
+	writeFrame(&buf, data)
+	if _, err := readFrame(bytes.NewReader(buf.Bytes()), len(data)-1); err == nil {
+		t.Fatal("readFrame accepted a frame over the limit")
+	}
+	if got, err := readFrame(&buf, len(data)); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("readFrame = %x, %v, want %x", got, err, data)
+	}
+}
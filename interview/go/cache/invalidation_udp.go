@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"net"
+)
+
+// multicastTransport 通过 UDP 组播发送失效消息，不需要中转服务，
+// 但是 UDP 可能丢包，需要配合较短的 L1TTL 兜底
+type multicastTransport struct {
+	recv *net.UDPConn
+	send *net.UDPConn
+	buf  []byte
+}
+
+// NewMulticastTransport 加入组播地址 group（例如 "239.0.0.1:9999"），
+// ifi 为空时由系统选择网卡。同一台主机上的实例也能收到消息
+func NewMulticastTransport(group string, ifi *net.Interface) (InvalidationTransport, error) {
+	addr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, err
+	}
+
+	recv, err := net.ListenMulticastUDP("udp", ifi, addr)
+	if err != nil {
+		return nil, err
+	}
+	send, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		recv.Close()
+		return nil, err
+	}
+	return &multicastTransport{
+		recv: recv,
+		send: send,
+		buf:  make([]byte, maxInvalidationSize),
+	}, nil
+}
+
+func (t *multicastTransport) Publish(ctx context.Context, msg Invalidation) error {
+	payload, err := encodeInvalidation(msg)
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	t.send.SetWriteDeadline(deadline)
+	_, err = t.send.Write(payload)
+	return err
+}
+
+// Receive 只在一个 goroutine 中调用，复用读缓冲区
+func (t *multicastTransport) Receive() (Invalidation, error) {
+	for {
+		n, _, err := t.recv.ReadFromUDP(t.buf)
+		if err != nil {
+			return Invalidation{}, err
+		}
+		// 组播地址上可能有其他程序的报文，忽略无法解析的报文
+		if msg, err := decodeInvalidation(t.buf[:n]); err == nil {
+			return msg, nil
+		}
+	}
+}
+
+func (t *multicastTransport) Close() error {
+	t.send.Close()
+	return t.recv.Close()
+}
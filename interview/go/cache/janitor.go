@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 每轮抽样的 key 数量，和 Redis 的 ACTIVE_EXPIRE_CYCLE_KEYS_PER_LOOP 一致
+	defaultJanitorSamples = 20
+	// 抽样中过期比例超过该值时继续下一轮
+	janitorRepeatRatio = 0.25
+	// 单次清理的时间上限，避免长时间占用写锁
+	janitorBudget = 25 * time.Millisecond
+	// map 的容量不会随删除收缩，元素少于峰值的 1/compactRatio 时重建 map
+	compactRatio   = 4
+	compactMinPeak = 1024
+)
+
+// JanitorStats 记录后台清理的统计信息
+type JanitorStats struct {
+	// Runs 是清理执行的次数
+	Runs uint64
+	// Sampled 是累计抽样检查的 key 数量
+	Sampled uint64
+	// Reclaimed 是累计删除的过期 key 数量
+	Reclaimed uint64
+	// Compactions 是重建 map 回收内存的次数
+	Compactions uint64
+}
+
+// janitor 按 Redis 的方式定期抽样删除过期 key：每轮随机检查 samples 个 key，
+// 过期比例超过 25% 时继续抽样，直到比例下降或者用完时间预算
+type janitor struct {
+	// 创建时就启动定时器，使用 FakeClock 时推进的时间不会因为 goroutine 还没运行而丢失
+	ticker  Ticker
+	samples int
+	sweep   func(n int) (sampled, reclaimed int)
+	compact func() bool
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	runs        atomic.Uint64
+	sampled     atomic.Uint64
+	reclaimed   atomic.Uint64
+	compactions atomic.Uint64
+}
+
+func newJanitor(clock Clock, interval time.Duration, samples int, sweep func(n int) (int, int), compact func() bool) *janitor {
+	if samples <= 0 {
+		samples = defaultJanitorSamples
+	}
+
+	j := &janitor{
+		ticker:  clock.NewTicker(interval),
+		samples: samples,
+		sweep:   sweep,
+		compact: compact,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go j.run()
+	return j
+}
+
+func (j *janitor) run() {
+	defer close(j.done)
+
+	defer j.ticker.Stop()
+
+	for {
+		select {
+		case <-j.ticker.Chan():
+			j.cycle()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+func (j *janitor) cycle() {
+	j.runs.Add(1)
+
+	deadline := time.Now().Add(janitorBudget)
+	for {
+		sampled, reclaimed := j.sweep(j.samples)
+		j.sampled.Add(uint64(sampled))
+		j.reclaimed.Add(uint64(reclaimed))
+
+		if sampled == 0 || float64(reclaimed) <= float64(sampled)*janitorRepeatRatio || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	if j.compact() {
+		j.compactions.Add(1)
+	}
+}
+
+func (j *janitor) close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
+func (j *janitor) stats() JanitorStats {
+	return JanitorStats{
+		Runs:        j.runs.Load(),
+		Sampled:     j.sampled.Load(),
+		Reclaimed:   j.reclaimed.Load(),
+		Compactions: j.compactions.Load(),
+	}
+}
+
+// 抽样检查最多 n 个 key，删除其中已经过期的
+func (c *LocalCache[K, V]) sweep(n int) (sampled, reclaimed int) {
+	now := c.clock.Now().UnixNano()
+	var expired []entry[K, V]
+
+	c.mu.Lock()
+	// map 的遍历起点是随机的，可以直接作为抽样
+	for key, it := range c.data {
+		if sampled == n {
+			break
+		}
+		sampled++
+
+		if c.dead(it, now) {
+			c.remove(key, it)
+			expired = append(expired, entry[K, V]{key, it.value, it.negative})
+		}
+	}
+	c.queueEvicted(expired, EvictExpired)
+	c.mu.Unlock()
+
+	c.events.flush()
+	c.notifyEvicted(expired, EvictExpired)
+	return sampled, len(expired)
+}
+
+// 元素数量远小于历史峰值时重建 map，释放底层 bucket 占用的内存
+func (c *LocalCache[K, V]) compact() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.peak < compactMinPeak || len(c.data)*compactRatio > c.peak {
+		return false
+	}
+
+	data := make(map[K]item[V], len(c.data))
+	for key, it := range c.data {
+		data[key] = it
+	}
+	c.data = data
+	c.peak = len(data)
+	return true
+}
+
+// JanitorStats 返回后台清理的统计信息，未开启清理时返回零值
+func (c *LocalCache[K, V]) JanitorStats() JanitorStats {
+	if c.janitor == nil {
+		return JanitorStats{}
+	}
+	return c.janitor.stats()
+}
+
+// Close 停止后台清理，开启 write-behind 时写完所有修改，开启持久化时写最后一次快照，可以重复调用
+func (c *LocalCache[K, V]) Close() error {
+	if c.janitor != nil {
+		c.janitor.close()
+	}
+	err := c.writer.close()
+	if c.persister != nil {
+		err = errors.Join(err, c.persister.close())
+	}
+	return err
+}
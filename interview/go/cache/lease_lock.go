@@ -0,0 +1,337 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLeaseTTL   = 10 * time.Second
+	defaultLeaseRetry = 50 * time.Millisecond
+)
+
+// LockStore 是租约锁使用的键值存储，所有操作必须是原子的
+type LockStore interface {
+	// Acquire 在 key 不存在时写入 value 并设置 ttl，同时把计数器 fence 加 1，返回新值作为 token；
+	// key 已经存在时返回 0。fence 不会过期
+	Acquire(ctx context.Context, key, fence, value string, ttl time.Duration) (int64, error)
+	// CompareAndExpire 在 key 的值等于 value 时把过期时间重新设置为 ttl，返回是否设置
+	CompareAndExpire(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// CompareAndDelete 在 key 的值等于 value 时删除 key，返回是否删除
+	CompareAndDelete(ctx context.Context, key, value string) (bool, error)
+}
+
+// LeaseConfig 配置租约锁
+type LeaseConfig struct {
+	// TTL 是租约的有效期，持有者崩溃后最多 TTL 之后其他进程可以获得锁，默认 10 秒
+	TTL time.Duration
+
+	// RenewInterval 是后台续约的间隔，默认 TTL 的三分之一
+	RenewInterval time.Duration
+
+	// Margin 是租约到期前提前认为锁丢失的时间，抵消时钟漂移和网络延迟，默认 TTL 的十分之一
+	Margin time.Duration
+
+	// RetryInterval 是锁被占用时重试的间隔，默认 50 毫秒
+	RetryInterval time.Duration
+
+	// Prefix 加在锁的 key 前面
+	Prefix string
+
+	// Logger 记录续约失败和锁丢失，为空时不输出
+	Logger *slog.Logger
+}
+
+// LeaseLocker 是基于键值存储的分布式锁：原子地加带过期时间的锁并递增计数器作为 fencing token，
+// 持有期间在后台续约
+type LeaseLocker struct {
+	store LockStore
+	cfg   LeaseConfig
+	log   *slog.Logger
+}
+
+var _ Locker = (*LeaseLocker)(nil)
+
+// 创建租约锁
+func NewLeaseLocker(store LockStore, cfg LeaseConfig) *LeaseLocker {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultLeaseTTL
+	}
+	if cfg.Margin <= 0 || cfg.Margin >= cfg.TTL {
+		cfg.Margin = cfg.TTL / 10
+	}
+	if cfg.RenewInterval <= 0 || cfg.RenewInterval >= cfg.TTL-cfg.Margin {
+		cfg.RenewInterval = cfg.TTL / 3
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultLeaseRetry
+	}
+
+	l := &LeaseLocker{store: store, cfg: cfg, log: cfg.Logger}
+	if l.log == nil {
+		l.log = discard
+	}
+	return l
+}
+
+func (l *LeaseLocker) Lock(ctx context.Context, key string) (Lease, error) {
+	lockKey := l.cfg.Prefix + key
+	owner := make([]byte, 16)
+	rand.Read(owner)
+	value := hex.EncodeToString(owner)
+
+	var (
+		acquired time.Time
+		token    int64
+	)
+	for {
+		// 租约从发出请求时开始计算，不包括网络往返的时间
+		acquired = time.Now()
+		var err error
+		// 加锁和递增在存储中一起完成，后获得锁的持有者 token 一定更大，
+		// 即使前一个持有者在拿到 token 之前就停顿到租约过期
+		token, err = l.store.Acquire(ctx, lockKey, lockKey+":fence", value, l.cfg.TTL)
+		if err != nil {
+			return nil, err
+		}
+		if token > 0 {
+			break
+		}
+
+		select {
+		case <-time.After(l.cfg.RetryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	lease := &lease{
+		locker:  l,
+		key:     lockKey,
+		value:   value,
+		token:   uint64(token),
+		renewed: acquired,
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go lease.renew()
+	return lease, nil
+}
+
+type lease struct {
+	locker *LeaseLocker
+	key    string
+	value  string
+	token  uint64
+
+	// 续约的 goroutine 退出之前只由它访问
+	renewed time.Time
+	lost    bool
+
+	done    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func (l *lease) Token() uint64 {
+	return l.token
+}
+
+func (l *lease) Done() <-chan struct{} {
+	return l.done
+}
+
+// renew 定期续约，存储返回锁已经不属于自己，或者到 renewed+TTL-Margin 都没有续约成功时认为锁丢失，
+// 后者由定时器触发，不依赖续约请求返回
+func (l *lease) renew() {
+	defer close(l.stopped)
+
+	cfg := l.locker.cfg
+	ticker := time.NewTicker(cfg.RenewInterval)
+	defer ticker.Stop()
+	expiry := time.NewTimer(time.Until(l.deadline()))
+	defer expiry.Stop()
+
+	var err error
+	for {
+		select {
+		case <-ticker.C:
+		case <-expiry.C:
+			l.lose(err)
+			return
+		case <-l.stop:
+			return
+		}
+
+		// 续约请求发出之前取时间，续约成功后租约最晚在 sent+TTL 过期
+		sent := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), min(cfg.RenewInterval, time.Until(l.deadline())))
+		var ok bool
+		ok, err = l.locker.store.CompareAndExpire(ctx, l.key, l.value, cfg.TTL)
+		cancel()
+
+		switch {
+		case err == nil && ok:
+			l.renewed = sent
+			if !expiry.Stop() {
+				<-expiry.C
+			}
+			expiry.Reset(time.Until(l.deadline()))
+			continue
+		case err != nil:
+			l.locker.log.Warn("cache lease renew failed", "key", l.key, "error", err)
+			continue
+		}
+
+		l.lose(err)
+		return
+	}
+}
+
+// deadline 是本地认为租约仍然有效的最后时间
+func (l *lease) deadline() time.Time {
+	return l.renewed.Add(l.locker.cfg.TTL - l.locker.cfg.Margin)
+}
+
+func (l *lease) lose(err error) {
+	l.locker.log.Warn("cache lease lost", "key", l.key, "token", l.token, "error", err)
+	l.lost = true
+	close(l.done)
+}
+
+func (l *lease) Unlock(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.stopped
+
+		if l.lost {
+			err = ErrLeaseLost
+			return
+		}
+		close(l.done)
+
+		var ok bool
+		ok, err = l.locker.store.CompareAndDelete(ctx, l.key, l.value)
+		if err == nil && !ok {
+			err = ErrLeaseLost
+		}
+	})
+	return err
+}
+
+// MemoryLockStore 是内存中的 LockStore，用于测试或者同一个进程内的多个实例
+type MemoryLockStore struct {
+	mu       sync.Mutex
+	locks    map[string]memoryLock
+	counters map[string]int64
+}
+
+type memoryLock struct {
+	value      string
+	expiration time.Time
+}
+
+var _ LockStore = (*MemoryLockStore)(nil)
+
+// 创建内存中的锁存储
+func NewMemoryLockStore() *MemoryLockStore {
+	return &MemoryLockStore{
+		locks:    make(map[string]memoryLock),
+		counters: make(map[string]int64),
+	}
+}
+
+// get 返回没有过期的锁，调用方持有锁
+func (s *MemoryLockStore) get(key string) (memoryLock, bool) {
+	lock, ok := s.locks[key]
+	if ok && time.Now().After(lock.expiration) {
+		delete(s.locks, key)
+		return memoryLock{}, false
+	}
+	return lock, ok
+}
+
+func (s *MemoryLockStore) Acquire(_ context.Context, key, fence, value string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.get(key); ok {
+		return 0, nil
+	}
+	s.locks[key] = memoryLock{value: value, expiration: time.Now().Add(ttl)}
+	s.counters[fence]++
+	return s.counters[fence], nil
+}
+
+func (s *MemoryLockStore) CompareAndExpire(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lock, ok := s.get(key); !ok || lock.value != value {
+		return false, nil
+	}
+	s.locks[key] = memoryLock{value: value, expiration: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryLockStore) CompareAndDelete(_ context.Context, key, value string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lock, ok := s.get(key); !ok || lock.value != value {
+		return false, nil
+	}
+	delete(s.locks, key)
+	return true, nil
+}
+
+// Redis 没有加锁同时递增、比较后续期和比较后删除的命令，使用 Lua 脚本保证原子性
+const (
+	acquireScript = `if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then return redis.call("INCR", KEYS[2]) else return 0 end`
+	renewScript   = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+	unlockScript  = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+var _ LockStore = (*RESPClient)(nil)
+
+// Acquire 在一个脚本中执行 SET key value NX PX ttl 和 INCR fence
+func (c *RESPClient) Acquire(ctx context.Context, key, fence, value string, ttl time.Duration) (int64, error) {
+	return c.evalInt(ctx, acquireScript, []string{key, fence}, value, strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+}
+
+func (c *RESPClient) CompareAndExpire(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return c.evalBool(ctx, renewScript, key, value, strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+}
+
+func (c *RESPClient) CompareAndDelete(ctx context.Context, key, value string) (bool, error) {
+	return c.evalBool(ctx, unlockScript, key, value)
+}
+
+// evalBool 执行只有一个 key 的脚本，脚本返回非 0 的整数时为 true
+func (c *RESPClient) evalBool(ctx context.Context, script, key string, args ...string) (bool, error) {
+	n, err := c.evalInt(ctx, script, []string{key}, args...)
+	return n != 0, err
+}
+
+// evalInt 执行返回整数的脚本
+func (c *RESPClient) evalInt(ctx context.Context, script string, keys []string, args ...string) (int64, error) {
+	cmd := append([]string{"EVAL", script, strconv.Itoa(len(keys))}, keys...)
+	reply, err := c.Do(ctx, append(cmd, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("cache: unexpected EVAL reply %T", reply)
+	}
+	return n, nil
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// hangingLockStore 的续约请求一直等到超时，模拟存储没有响应
+type hangingLockStore struct {
+	*MemoryLockStore
+}
+
+func (hangingLockStore) CompareAndExpire(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestLeaseDoneBeforeExpiry(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+	locker := NewLeaseLocker(hangingLockStore{NewMemoryLockStore()}, LeaseConfig{TTL: ttl})
+
+	start := time.Now()
+	lease, err := locker.Lock(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-lease.Done():
+	case <-time.After(2 * ttl):
+		t.Fatal("Done not closed after the lease expired")
+	}
+	if elapsed := time.Since(start); elapsed >= ttl {
+		t.Fatalf("Done closed after %v, want before the %v TTL", elapsed, ttl)
+	}
+	if err := lease.Unlock(context.Background()); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Unlock = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestLeaseRenewKeepsLock(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+	locker := NewLeaseLocker(NewMemoryLockStore(), LeaseConfig{TTL: ttl})
+
+	lease, err := locker.Lock(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lease.Done():
+		t.Fatal("lease lost while renewing")
+	case <-time.After(5 * ttl):
+	}
+	if err := lease.Unlock(context.Background()); err != nil {
+		t.Fatalf("Unlock = %v", err)
+	}
+}
+
+// stallingLockStore 在第一次加锁成功之后、返回之前调用 stall，模拟持有者在拿到 token 之前停顿
+type stallingLockStore struct {
+	*MemoryLockStore
+	stall func()
+}
+
+func (s *stallingLockStore) Acquire(ctx context.Context, key, fence, value string, ttl time.Duration) (int64, error) {
+	token, err := s.MemoryLockStore.Acquire(ctx, key, fence, value, ttl)
+	if stall := s.stall; token > 0 && stall != nil {
+		s.stall = nil
+		stall()
+	}
+	return token, err
+}
+
+// 租约在加锁和返回 token 之间过期，后获得锁的持有者 token 仍然更大
+func TestLeaseTokenIssuedWithAcquisition(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	store := &stallingLockStore{MemoryLockStore: NewMemoryLockStore()}
+	locker := NewLeaseLocker(store, LeaseConfig{TTL: ttl})
+	ctx := context.Background()
+
+	var second Lease
+	store.stall = func() {
+		time.Sleep(2 * ttl)
+		var err error
+		if second, err = locker.Lock(ctx, "key"); err != nil {
+			t.Error(err)
+		}
+	}
+	first, err := locker.Lock(ctx, "key")
+	if err != nil || second == nil {
+		t.Fatalf("Lock = %v, second holder %v", err, second)
+	}
+	defer second.Unlock(ctx)
+
+	if first.Token() >= second.Token() {
+		t.Fatalf("stalled holder token %d, later holder token %d, want the later one larger", first.Token(), second.Token())
+	}
+	select {
+	case <-first.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed for the expired lease")
+	}
+}
+
+func TestLeaseLockerOverRESP(t *testing.T) {
+	server, err := NewRESPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := NewRESPClient(server.Addr(), 1)
+	defer client.Close()
+
+	locker := NewLeaseLocker(client, LeaseConfig{TTL: time.Second})
+	ctx := context.Background()
+	for want := uint64(1); want <= 3; want++ {
+		lease, err := locker.Lock(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lease.Token() != want {
+			t.Fatalf("Token = %d, want %d", lease.Token(), want)
+		}
+		if err := lease.Unlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lease, err := locker.Lock(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Unlock(ctx)
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := locker.Lock(timeout, "key"); err == nil {
+		t.Fatal("Lock succeeded while the lease was held")
+	}
+}
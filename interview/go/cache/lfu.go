@@ -0,0 +1,116 @@
+package cache
+
+import "container/heap"
+
+// LFU 淘汰访问次数最少的 key，次数相同时淘汰最久没有被访问的
+type LFU[K comparable] struct {
+	h     lfuHeap[K]
+	items map[K]*lfuEntry[K]
+	// 单调递增的访问序号，用于次数相同时比较先后
+	tick uint64
+	// 最近一次 Victim 选出的 key，Reinsert 时保留它的访问次数
+	last *lfuEntry[K]
+}
+
+type lfuEntry[K comparable] struct {
+	key   K
+	freq  int
+	tick  uint64
+	index int
+}
+
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) Len() int { return len(h) }
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x any) {
+	e := x.(*lfuEntry[K])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// 创建 LFU 淘汰策略
+func NewLFU[K comparable]() *LFU[K] {
+	return &LFU[K]{
+		items: make(map[K]*lfuEntry[K]),
+	}
+}
+
+func (l *LFU[K]) Add(key K) {
+	if _, ok := l.items[key]; ok {
+		l.Access(key)
+		return
+	}
+
+	l.tick++
+	e := &lfuEntry[K]{key: key, freq: 1, tick: l.tick}
+	l.items[key] = e
+	heap.Push(&l.h, e)
+}
+
+func (l *LFU[K]) Access(key K) {
+	e, ok := l.items[key]
+	if !ok {
+		return
+	}
+
+	l.tick++
+	e.freq++
+	e.tick = l.tick
+	heap.Fix(&l.h, e.index)
+}
+
+func (l *LFU[K]) Remove(key K) {
+	if e, ok := l.items[key]; ok {
+		heap.Remove(&l.h, e.index)
+		delete(l.items, key)
+	}
+}
+
+func (l *LFU[K]) Victim() (K, bool) {
+	if l.h.Len() == 0 {
+		var zero K
+		return zero, false
+	}
+
+	e := heap.Pop(&l.h).(*lfuEntry[K])
+	delete(l.items, e.key)
+	l.last = e
+	return e.key, true
+}
+
+func (l *LFU[K]) Reinsert(key K) {
+	e := l.last
+	l.last = nil
+	if _, ok := l.items[key]; ok || e == nil || e.key != key {
+		l.Add(key)
+		return
+	}
+
+	l.tick++
+	e.tick = l.tick
+	l.items[key] = e
+	heap.Push(&l.h, e)
+}
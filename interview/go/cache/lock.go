@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrLeaseLost 表示锁在释放前已经因为过期或续约失败被其他持有者拿走
+var ErrLeaseLost = errors.New("cache: lease lost")
+
+// Locker 是按 key 加锁的互斥锁，用于让多个进程中同一个 key 只有一个在访问数据源
+type Locker interface {
+	// Lock 阻塞直到获得 key 的锁或者 ctx 结束
+	Lock(ctx context.Context, key string) (Lease, error)
+}
+
+// Lease 是一次持有的锁
+type Lease interface {
+	// Token 是 fencing token，同一个 key 后获得锁的持有者 token 更大，
+	// 数据源可以据此拒绝锁过期后仍在写入的旧持有者
+	Token() uint64
+	// Done 在锁丢失或者释放后关闭，之后不应该再写入共享的数据
+	Done() <-chan struct{}
+	// Unlock 释放锁，锁已经丢失时返回 ErrLeaseLost
+	Unlock(ctx context.Context) error
+}
+
+type leaseKey struct{}
+
+// WithLease 把持有的锁放入 ctx，加载函数通过 LeaseFromContext 取出 fencing token
+func WithLease(ctx context.Context, lease Lease) context.Context {
+	return context.WithValue(ctx, leaseKey{}, lease)
+}
+
+// LeaseFromContext 返回 GetOrLoad 在加载前获得的锁
+func LeaseFromContext(ctx context.Context) (Lease, bool) {
+	lease, ok := ctx.Value(leaseKey{}).(Lease)
+	return lease, ok
+}
+
+// LocalLocker 是进程内的 Locker，锁只在内存中，不会过期
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]*localLock
+	token atomic.Uint64
+}
+
+var _ Locker = (*LocalLocker)(nil)
+
+// localLock 是容量为 1 的信号量，waiters 为 0 时从 map 中删除
+type localLock struct {
+	sem     chan struct{}
+	waiters int
+}
+
+// 创建进程内的锁
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{locks: make(map[string]*localLock)}
+}
+
+func (l *LocalLocker) Lock(ctx context.Context, key string) (Lease, error) {
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &localLock{sem: make(chan struct{}, 1)}
+		l.locks[key] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	select {
+	case lock.sem <- struct{}{}:
+		return &localLease{locker: l, key: key, lock: lock, token: l.token.Add(1), done: make(chan struct{})}, nil
+	case <-ctx.Done():
+		l.release(key, lock)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *LocalLocker) release(key string, lock *localLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock.waiters--
+	if lock.waiters == 0 {
+		delete(l.locks, key)
+	}
+}
+
+type localLease struct {
+	locker *LocalLocker
+	key    string
+	lock   *localLock
+	token  uint64
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (l *localLease) Token() uint64 {
+	return l.token
+}
+
+func (l *localLease) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *localLease) Unlock(context.Context) error {
+	l.once.Do(func() {
+		close(l.done)
+		<-l.lock.sem
+		l.locker.release(l.key, l.lock)
+	})
+	return nil
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler 丢弃所有日志，Enabled 返回 false，调用方不会构造日志记录
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discard = slog.New(discardHandler{})
+
+// 模拟函数使用的日志，默认不输出
+var logger = discard
+
+// SetLogger 设置模拟函数和它们创建的缓存使用的日志，nil 表示不输出，需要在调用模拟函数之前设置
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = discard
+	}
+	logger = l
+}
+
+func (c *LocalCache[K, V]) debug(ctx context.Context, msg string, key K, attrs ...slog.Attr) {
+	if !c.log.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	c.log.LogAttrs(ctx, slog.LevelDebug, msg, append([]slog.Attr{slog.Any("key", key)}, attrs...)...)
+}
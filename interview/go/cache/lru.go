@@ -0,0 +1,59 @@
+package cache
+
+import "container/list"
+
+// LRU 淘汰最久没有被访问的 key
+type LRU[K comparable] struct {
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+// 创建 LRU 淘汰策略
+func NewLRU[K comparable]() *LRU[K] {
+	return &LRU[K]{
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+func (l *LRU[K]) Add(key K) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.ll.PushFront(key)
+}
+
+func (l *LRU[K]) Access(key K) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+	}
+}
+
+func (l *LRU[K]) Remove(key K) {
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+func (l *LRU[K]) Reinsert(key K) {
+	l.Add(key)
+}
+
+// Len 返回记录的 key 数量
+func (l *LRU[K]) Len() int {
+	return l.ll.Len()
+}
+
+func (l *LRU[K]) Victim() (K, bool) {
+	e := l.ll.Back()
+	if e == nil {
+		var zero K
+		return zero, false
+	}
+
+	key := l.ll.Remove(e).(K)
+	delete(l.items, key)
+	return key, true
+}
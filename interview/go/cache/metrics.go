@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricsHandler 以 Prometheus 文本格式导出缓存的统计信息，caches 的 key 作为 cache 标签的值。
+// 过滤器实现了 FPREstimator 时可以放在 filters 中一起导出估算的误判率
+func MetricsHandler(caches map[string]StatsProvider, filters map[string]FPREstimator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, caches, filters)
+	})
+}
+
+type metric struct {
+	name  string
+	help  string
+	typ   string
+	value func(s *Stats) float64
+}
+
+var metrics = []metric{
+	{"cache_hits_total", "Number of cache hits.", "counter", func(s *Stats) float64 { return float64(s.Hits) }},
+	{"cache_stale_hits_total", "Number of stale values served while refreshing.", "counter", func(s *Stats) float64 { return float64(s.StaleHits) }},
+	{"cache_negative_hits_total", "Number of negative cache hits.", "counter", func(s *Stats) float64 { return float64(s.NegativeHits) }},
+	{"cache_misses_total", "Number of cache misses.", "counter", func(s *Stats) float64 { return float64(s.Misses) }},
+	{"cache_filter_rejects_total", "Number of keys rejected by the membership filter.", "counter", func(s *Stats) float64 { return float64(s.FilterRejects) }},
+	{"cache_loads_total", "Number of loads from the backing store.", "counter", func(s *Stats) float64 { return float64(s.Loads) }},
+	{"cache_load_errors_total", "Number of failed loads from the backing store.", "counter", func(s *Stats) float64 { return float64(s.LoadErrors) }},
+	{"cache_inflight_loads", "Current number of loads from the backing store.", "gauge", func(s *Stats) float64 { return float64(s.InflightLoads) }},
+	{"cache_rate_limited_total", "Number of loads rejected by the rate limiter.", "counter", func(s *Stats) float64 { return float64(s.RateLimited) }},
+	{"cache_circuit_rejected_total", "Number of loads rejected by the open circuit breaker.", "counter", func(s *Stats) float64 { return float64(s.CircuitRejected) }},
+	{"cache_breaker_opens_total", "Number of times the circuit breaker opened.", "counter", func(s *Stats) float64 { return float64(s.BreakerOpens) }},
+	{"cache_breaker_state", "Circuit breaker state: 0 closed, 1 open, 2 half-open.", "gauge", func(s *Stats) float64 { return float64(s.Breaker) }},
+	{"cache_pending_writes", "Current number of keys waiting to be written behind.", "gauge", func(s *Stats) float64 { return float64(s.PendingWrites) }},
+	{"cache_flushed_writes_total", "Number of writes flushed to the backing store.", "counter", func(s *Stats) float64 { return float64(s.FlushedWrites) }},
+	{"cache_failed_writes_total", "Number of writes requeued after exhausting retries.", "counter", func(s *Stats) float64 { return float64(s.FailedWrites) }},
+	{"cache_evictions_total", "Number of entries evicted for capacity.", "counter", func(s *Stats) float64 { return float64(s.Evictions) }},
+	{"cache_expirations_total", "Number of expired entries removed.", "counter", func(s *Stats) float64 { return float64(s.Expirations) }},
+	{"cache_entries", "Current number of entries.", "gauge", func(s *Stats) float64 { return float64(s.Size) }},
+	{"cache_bytes", "Estimated size of entries in bytes.", "gauge", func(s *Stats) float64 { return float64(s.Bytes) }},
+}
+
+// WriteMetrics 把统计信息按 Prometheus 文本格式写入 w
+func WriteMetrics(w io.Writer, caches map[string]StatsProvider, filters map[string]FPREstimator) error {
+	bw := bufio.NewWriter(w)
+
+	names := sortedKeys(caches)
+	stats := make([]Stats, len(names))
+	for i, name := range names {
+		stats[i] = caches[name].Stats()
+	}
+
+	for _, m := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for i, name := range names {
+			fmt.Fprintf(bw, "%s{cache=%s} %s\n", m.name, quoteLabel(name), formatFloat(m.value(&stats[i])))
+		}
+	}
+
+	const latency = "cache_load_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Latency of loads from the backing store.\n# TYPE %s histogram\n", latency, latency)
+	for i, name := range names {
+		h := stats[i].LoadLatency
+		label := quoteLabel(name)
+		for j, le := range h.Buckets {
+			fmt.Fprintf(bw, "%s_bucket{cache=%s,le=\"%s\"} %d\n", latency, label, formatFloat(le), h.Counts[j])
+		}
+		fmt.Fprintf(bw, "%s_bucket{cache=%s,le=\"+Inf\"} %d\n", latency, label, h.Count)
+		fmt.Fprintf(bw, "%s_sum{cache=%s} %s\n", latency, label, formatFloat(h.Sum))
+		fmt.Fprintf(bw, "%s_count{cache=%s} %d\n", latency, label, h.Count)
+	}
+
+	if len(filters) > 0 {
+		const fpr = "cache_filter_estimated_false_positive_rate"
+		fmt.Fprintf(bw, "# HELP %s Estimated false positive rate of the membership filter.\n# TYPE %s gauge\n", fpr, fpr)
+		for _, name := range sortedKeys(filters) {
+			fmt.Fprintf(bw, "%s{filter=%s} %s\n", fpr, quoteLabel(name), formatFloat(filters[name].EstimatedFPR()))
+		}
+	}
+
+	return bw.Flush()
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 标签值需要转义反斜杠、双引号和换行
+func quoteLabel(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
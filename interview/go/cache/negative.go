@@ -0,0 +1,42 @@
+package cache
+
+import "time"
+
+const defaultMaxNegative = 1024
+
+// 写入负缓存，使用 NegativeTTL 作为过期时间
+func (c *LocalCache[K, V]) setNegative(key K, delta time.Duration) {
+	ttl := c.jitter(c.cfg.NegativeTTL)
+	it := item[V]{
+		expiration: c.clock.Now().Add(ttl).UnixNano(),
+		ttl:        ttl,
+		delta:      delta,
+		negative:   true,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(key, it)
+}
+
+// storeNegative 记录负缓存并在超过上限时淘汰最早写入的，调用方持有写锁
+func (c *LocalCache[K, V]) storeNegative(key K) {
+	c.negatives.Add(key)
+
+	limit := c.cfg.MaxNegative
+	if limit <= 0 {
+		limit = defaultMaxNegative
+	}
+	for c.negatives.Len() > limit {
+		victim, _ := c.negatives.Victim()
+		delete(c.data, victim)
+	}
+}
+
+func (c *LocalCache[K, V]) negativeLen() int {
+	if c.negatives == nil {
+		return 0
+	}
+	return c.negatives.Len()
+}
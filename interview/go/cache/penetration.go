@@ -0,0 +1,65 @@
+// The demo is for cache penetration
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 模拟数据库中不存在该 key
+func queryMissingFromDB(ctx context.Context, key string) (string, error) {
+	logger.Info("querying from DB", "key", key)
+	select {
+	case <-time.After(100 * time.Millisecond): // 模拟数据库延迟
+		return "", ErrNotFound
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// 模拟大量请求访问数据库中不存在的 key
+func SimulateCachePenetration() {
+	cache := NewLocalCache(Config[string, string]{
+		BreakdownLock: true,
+		NegativeTTL:   1 * time.Second, // 负缓存的过期时间比正常数据短
+		MaxNegative:   100,
+		MaxEntries:    100,
+		Logger:        logger,
+	})
+	var wg sync.WaitGroup
+
+	// 空字符串是真实存在的值，和不存在的 key 可以区分
+	cache.Set("emptykey", "", 5*time.Second)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, key := range []string{"emptykey", "hotdogkey"} {
+				value, err := cache.GetOrLoad(context.Background(), key, 5*time.Second, queryMissingFromDB)
+				if errors.Is(err, ErrNotFound) {
+					logger.Info("key not found", "key", key)
+					continue
+				}
+				logger.Info("got value", "key", key, "value", value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 模拟扫描大量不存在的 key，负缓存有单独的上限，不会挤掉正常数据
+	scan := func(ctx context.Context, key string) (string, error) {
+		return "", ErrNotFound
+	}
+	for i := 0; i < 10000; i++ {
+		cache.GetOrLoad(context.Background(), fmt.Sprintf("scan%d", i), 5*time.Second, scan)
+	}
+
+	value, found := cache.Get("emptykey")
+	logger.Info("after scanning", "key", "emptykey", "found", found, "value", value, "entries", cache.Len())
+}
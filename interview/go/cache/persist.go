@@ -0,0 +1,526 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// 追加日志每秒刷盘一次
+const appendLogSyncInterval = time.Second
+
+const (
+	opSet byte = iota + 1
+	opDelete
+)
+
+// PersistConfig 配置缓存的持久化，Path 为空时不开启
+type PersistConfig[K comparable, V any] struct {
+	// Path 是快照文件的路径，创建缓存时从快照和追加日志恢复数据，Close 时再写一次快照
+	Path string
+
+	// Keys 和 Values 编码快照中的 key 和值，默认使用 DefaultCodec
+	Keys   Codec[K]
+	Values Codec[V]
+
+	// Interval 大于 0 时在后台每隔 Interval 写一次快照
+	Interval time.Duration
+
+	// AppendLog 为 true 时在两次快照之间把 Set 和 Delete 追加到 Path + ".aof"，
+	// 每秒刷盘一次，进程崩溃时最多丢失 1 秒内的写入
+	AppendLog bool
+}
+
+func persistCodecs[K comparable, V any](cfg PersistConfig[K, V]) (Codec[K], Codec[V]) {
+	keys, values := cfg.Keys, cfg.Values
+	if keys == nil {
+		keys = DefaultCodec[K]()
+	}
+	if values == nil {
+		values = DefaultCodec[V]()
+	}
+	return keys, values
+}
+
+// appendLog 记录两次快照之间的写入，方法可以在 nil 上调用
+type appendLog[K comparable, V any] struct {
+	path   string
+	keys   Codec[K]
+	values Codec[V]
+	clock  Clock
+	log    *slog.Logger
+
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	buf  []byte
+	// 写入失败后只记录一次日志，下一次轮转时恢复
+	failed bool
+	// 轮转期间新的记录先写入 pending，old 和 oldW 是等待移到 path.1 的旧日志
+	pending *bytes.Buffer
+	old     *os.File
+	oldW    *bufio.Writer
+}
+
+func openAppendLog[K comparable, V any](path string, keys Codec[K], values Codec[V], clock Clock, log *slog.Logger) (*appendLog[K, V], error) {
+	l := &appendLog[K, V]{path: path, keys: keys, values: values, clock: clock, log: log}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *appendLog[K, V]) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file, l.w, l.failed = file, bufio.NewWriter(file), false
+	if info.Size() == 0 {
+		return writeHeader(l.w, appendLogMagic, l.clock.Now())
+	}
+	return nil
+}
+
+// set 记录写入，调用方持有缓存的写锁，保证日志的顺序和写入的顺序一致
+func (l *appendLog[K, V]) set(key K, it item[V]) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buf, err := appendEntry(append(l.buf[:0], opSet), l.keys, l.values, key, it)
+	l.write(buf, err)
+}
+
+// delete 记录删除，调用方持有缓存的写锁
+func (l *appendLog[K, V]) delete(key K) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	k, err := l.keys.Encode(key)
+	l.write(append(append(l.buf[:0], opDelete), k...), err)
+}
+
+func (l *appendLog[K, V]) write(payload []byte, err error) {
+	l.buf = payload
+	if err == nil && l.w != nil {
+		err = writeRecord(l.w, payload)
+	}
+	if err != nil && !l.failed {
+		l.failed = true
+		l.log.Warn("cache append log write failed", "path", l.path, "error", err)
+	}
+}
+
+// sync 把缓冲区写入文件并刷盘。写入缓存时会持有 l.mu，刷盘在释放 l.mu 之后进行
+func (l *appendLog[K, V]) sync() error {
+	l.mu.Lock()
+	file := l.file
+	var err error
+	if file != nil {
+		err = l.w.Flush()
+	}
+	l.mu.Unlock()
+
+	if file == nil || err != nil {
+		return err
+	}
+	// 期间轮转关闭了文件时，轮转已经刷过盘
+	if err := file.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// rotate 开始轮转，调用方持有缓存的读锁，这里只切换写入目标，不访问文件：
+// 之后的记录先写入内存，由 finishRotate 在释放缓存的锁之后写入新的日志
+func (l *appendLog[K, V]) rotate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.old, l.oldW = l.file, l.w
+	l.pending = new(bytes.Buffer)
+	l.file, l.w = nil, bufio.NewWriter(l.pending)
+}
+
+// finishRotate 把旧日志移到 path.1 并新建日志，再写入轮转期间的记录。
+// 上一次快照失败时 path.1 还在，把旧日志的记录接在它后面
+func (l *appendLog[K, V]) finishRotate() error {
+	l.mu.Lock()
+	old, oldW := l.old, l.oldW
+	l.old, l.oldW = nil, nil
+	l.mu.Unlock()
+
+	err := closeFile(old, oldW)
+	if err == nil {
+		rotated := l.path + ".1"
+		if _, serr := os.Stat(rotated); errors.Is(serr, fs.ErrNotExist) {
+			err = os.Rename(l.path, rotated)
+		} else {
+			err = appendRecords(rotated, l.path)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 移动失败时继续追加到原来的日志，记录的顺序不变
+	pending := l.pending
+	l.pending = nil
+	if l.w != nil {
+		l.w.Flush()
+	}
+	if oerr := l.open(); oerr != nil {
+		l.file, l.w = nil, nil
+		return errors.Join(err, oerr)
+	}
+	if _, werr := l.w.Write(pending.Bytes()); werr != nil {
+		err = errors.Join(err, werr)
+	}
+	return err
+}
+
+func closeFile(file *os.File, w *bufio.Writer) error {
+	if file == nil {
+		return nil
+	}
+	err := w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (l *appendLog[K, V]) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := closeFile(l.file, l.w)
+	l.file, l.w = nil, nil
+	return err
+}
+
+// appendRecords 把 src 中文件头之后的记录追加到 dst 并删除 src
+func appendRecords(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := readHeader(in, appendLogMagic); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// replayAppendLog 按顺序重放日志中的记录，返回重放的条数和最后一条完整记录结束的位置，
+// 文件头损坏时位置为 0。最后一条记录不完整时认为是写到一半崩溃，忽略这条记录
+func replayAppendLog[K comparable, V any](path string, keys Codec[K], values Codec[V], set func(K, item[V]), del func(K)) (n int, end int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	if _, err := readHeader(r, appendLogMagic); err != nil {
+		return 0, 0, fmt.Errorf("cache: replay %s: %w", path, err)
+	}
+	end = headerSize
+
+	for {
+		payload, err := readRecord(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return n, end, nil
+		}
+		if err == nil && len(payload) == 0 {
+			err = fmt.Errorf("%w: empty record", ErrCorruptSnapshot)
+		}
+		if err != nil {
+			return n, end, fmt.Errorf("cache: replay %s: %w", path, err)
+		}
+
+		switch payload[0] {
+		case opSet:
+			key, it, err := decodeEntry(payload[1:], keys, values)
+			if err != nil {
+				return n, end, fmt.Errorf("cache: replay %s: %w", path, err)
+			}
+			set(key, it)
+		case opDelete:
+			key, err := keys.Decode(payload[1:])
+			if err != nil {
+				return n, end, fmt.Errorf("cache: replay %s: %w", path, err)
+			}
+			del(key)
+		default:
+			return n, end, fmt.Errorf("cache: replay %s: %w: unknown op %d", path, ErrCorruptSnapshot, payload[0])
+		}
+		n++
+		end += int64(4 + len(payload) + 4)
+	}
+}
+
+// trimAppendLog 截掉 end 之后没有重放的内容，之后追加的记录才能被重放。文件头损坏时删除整个文件
+func trimAppendLog(path string, end int64) (dropped int64, err error) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() <= end {
+		return 0, err
+	}
+	if end == 0 {
+		return info.Size(), os.Remove(path)
+	}
+	return info.Size() - end, os.Truncate(path, end)
+}
+
+// persistTarget 是可以持久化的缓存
+type persistTarget[K comparable, V any] interface {
+	capture(rotate func()) []snapshotEntry[K, V]
+	restoreEntry(key K, it item[V])
+	// 重放日志中的删除只修改内存，不能再交给 write-behind 写入后端存储
+	Invalidate(key K)
+}
+
+// persister 负责恢复、定期快照和追加日志
+type persister[K comparable, V any] struct {
+	cfg    PersistConfig[K, V]
+	keys   Codec[K]
+	values Codec[V]
+	target persistTarget[K, V]
+	aof    *appendLog[K, V]
+	clock  Clock
+	log    *slog.Logger
+
+	// 串行执行快照
+	mu sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// newPersister 从快照和追加日志恢复数据，然后打开追加日志并启动后台快照。
+// 恢复失败时记录日志并从空缓存开始，缓存不应该因为持久化失败而不可用
+func newPersister[K comparable, V any](cfg PersistConfig[K, V], target persistTarget[K, V], clock Clock, log *slog.Logger) (*persister[K, V], error) {
+	keys, values := persistCodecs(cfg)
+	p := &persister[K, V]{
+		cfg:    cfg,
+		keys:   keys,
+		values: values,
+		target: target,
+		clock:  clock,
+		log:    log,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	logs := p.restore()
+
+	if cfg.AppendLog {
+		var err error
+		if p.aof, err = openAppendLog(p.aofPath(), keys, values, clock, log); err != nil {
+			return nil, err
+		}
+	}
+	// 恢复时已经截掉了日志中无法重放的部分，立即写快照丢弃旧日志，失败时在截断后的日志后面继续追加
+	if logs {
+		if err := p.snapshot(); err != nil {
+			p.log.Warn("cache snapshot failed", "path", cfg.Path, "error", err)
+		}
+	}
+	go p.run()
+	return p, nil
+}
+
+func (p *persister[K, V]) aofPath() string {
+	return p.cfg.Path + ".aof"
+}
+
+// restore 恢复快照并重放日志，返回是否存在日志文件
+func (p *persister[K, V]) restore() (logs bool) {
+	start := p.clock.Now()
+
+	entries, err := readSnapshotFile(p.cfg.Path, p.keys, p.values)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		p.log.Warn("cache snapshot restore failed", "path", p.cfg.Path, "error", err)
+	default:
+		for _, e := range entries {
+			p.target.restoreEntry(e.key, e.it)
+		}
+	}
+
+	// 先重放轮转出来的旧日志，再重放当前日志
+	replayed := 0
+	for _, path := range []string{p.aofPath() + ".1", p.aofPath()} {
+		n, end, err := replayAppendLog(path, p.keys, p.values, p.target.restoreEntry, p.target.Invalidate)
+		replayed += n
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		logs = true
+		if err != nil {
+			p.log.Warn("cache append log replay failed", "path", path, "error", err)
+		}
+		if dropped, err := trimAppendLog(path, end); err != nil {
+			p.log.Warn("cache append log trim failed", "path", path, "error", err)
+		} else if dropped > 0 {
+			p.log.Warn("cache append log trimmed", "path", path, "dropped", dropped)
+		}
+	}
+
+	p.log.Info("cache restored", "path", p.cfg.Path, "entries", len(entries), "replayed", replayed,
+		"latency", p.clock.Now().Sub(start))
+	return logs
+}
+
+func (p *persister[K, V]) run() {
+	defer close(p.done)
+
+	var snapshots, syncs <-chan time.Time
+	if p.cfg.Interval > 0 {
+		ticker := p.clock.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		snapshots = ticker.Chan()
+	}
+	if p.aof != nil {
+		ticker := p.clock.NewTicker(appendLogSyncInterval)
+		defer ticker.Stop()
+		syncs = ticker.Chan()
+	}
+
+	for {
+		select {
+		case <-snapshots:
+			if err := p.snapshot(); err != nil {
+				p.log.Warn("cache snapshot failed", "path", p.cfg.Path, "error", err)
+			}
+		case <-syncs:
+			if err := p.aof.sync(); err != nil {
+				p.log.Warn("cache append log sync failed", "path", p.aofPath(), "error", err)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// snapshot 写入快照，成功后删除快照已经包含的日志
+func (p *persister[K, V]) snapshot() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var rotate func()
+	if p.aof != nil {
+		rotate = p.aof.rotate
+	}
+	entries := p.target.capture(rotate)
+	if p.aof != nil {
+		if err := p.aof.finishRotate(); err != nil {
+			return err
+		}
+	}
+	if err := writeSnapshotFile(p.cfg.Path, p.clock.Now(), entries, p.keys, p.values); err != nil {
+		return err
+	}
+
+	os.Remove(p.aofPath() + ".1")
+	if p.aof == nil {
+		// 之前开启过追加日志，恢复时已经重放
+		os.Remove(p.aofPath())
+	}
+	return nil
+}
+
+// close 停止后台快照，写最后一次快照并关闭追加日志
+func (p *persister[K, V]) close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+
+		err = p.snapshot()
+		if p.aof != nil {
+			if cerr := p.aof.close(); err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
+
+// persist 恢复数据并开启持久化，失败时记录日志并关闭持久化
+func (c *LocalCache[K, V]) persist(cfg PersistConfig[K, V]) {
+	p, err := newPersister[K, V](cfg, c, c.clock, c.log)
+	if err != nil {
+		c.log.Warn("cache persistence disabled", "path", cfg.Path, "error", err)
+		return
+	}
+	c.persister, c.journal = p, p.aof
+}
+
+// Snapshot 立即写一次快照，没有开启持久化时返回错误
+func (c *LocalCache[K, V]) Snapshot() error {
+	if c.persister == nil {
+		return errors.New("cache: persistence not enabled")
+	}
+	return c.persister.snapshot()
+}
+
+func (c *ShardedCache[K, V]) persist(cfg PersistConfig[K, V]) {
+	log := c.shards[0].log
+	p, err := newPersister[K, V](cfg, c, c.shards[0].clock, log)
+	if err != nil {
+		log.Warn("cache persistence disabled", "path", cfg.Path, "error", err)
+		return
+	}
+	c.persister = p
+	for _, shard := range c.shards {
+		shard.journal = p.aof
+	}
+}
+
+// Snapshot 和 LocalCache.Snapshot 相同
+func (c *ShardedCache[K, V]) Snapshot() error {
+	if c.persister == nil {
+		return errors.New("cache: persistence not enabled")
+	}
+	return c.persister.snapshot()
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// replayAll 按恢复时的顺序重放 path.1 和 path，返回最后的数据
+func replayAll(t *testing.T, path string) map[string]int {
+	t.Helper()
+	data := make(map[string]int)
+	for _, p := range []string{path + ".1", path} {
+		_, _, err := replayAppendLog(p, StringCodec{}, DefaultCodec[int](),
+			func(key string, it item[int]) { data[key] = it.value },
+			func(key string) { delete(data, key) })
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("replay %s: %v", p, err)
+		}
+	}
+	return data
+}
+
+func testSnapshot(t *testing.T) ([]snapshotEntry[string, int], []byte) {
+	t.Helper()
+	entries := []snapshotEntry[string, int]{
+		{"a", item[int]{value: 1, expiration: epoch.Add(time.Hour).UnixNano(), ttl: time.Hour, delta: time.Millisecond}},
+		{"b", item[int]{value: 0, expiration: epoch.Add(time.Minute).UnixNano(), ttl: time.Minute}},
+		{"missing", item[int]{negative: true, expiration: epoch.Add(time.Second).UnixNano(), ttl: time.Second}},
+	}
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, epoch, entries, StringCodec{}, DefaultCodec[int]()); err != nil {
+		t.Fatal(err)
+	}
+	return entries, buf.Bytes()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	entries, data := testSnapshot(t)
+
+	created, err := readHeader(bytes.NewReader(data), snapshotMagic)
+	if err != nil || !created.Equal(epoch) {
+		t.Fatalf("header created = %v, %v, want %v", created, err, epoch)
+	}
+
+	got, err := readSnapshot(bytes.NewReader(data), StringCodec{}, DefaultCodec[int]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("read %d entries, want %d", len(got), len(entries))
+	}
+	for i, e := range entries {
+		if got[i] != e {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], e)
+		}
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	_, data := testSnapshot(t)
+
+	// 任何位置截断都不能返回部分数据
+	for n := 0; n < len(data); n++ {
+		if _, err := readSnapshot(bytes.NewReader(data[:n]), StringCodec{}, DefaultCodec[int]()); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Fatalf("truncated to %d bytes: err = %v, want ErrCorruptSnapshot", n, err)
+		}
+	}
+
+	// 修改第一条记录的内容，校验和不匹配
+	corrupt := bytes.Clone(data)
+	corrupt[headerSize+4+1]++
+	if _, err := readSnapshot(bytes.NewReader(corrupt), StringCodec{}, DefaultCodec[int]()); !errors.Is(err, ErrCorruptSnapshot) {
+		t.Fatalf("checksum mismatch: err = %v, want ErrCorruptSnapshot", err)
+	}
+}
+
+// writeTestLog 写入 a=1、b=2、c=3 三条记录，返回文件大小
+func writeTestLog(t *testing.T, path string) int64 {
+	t.Helper()
+	l, err := openAppendLog(path, StringCodec{}, DefaultCodec[int](), NewFakeClock(epoch), discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b", "c"} {
+		l.set(key, item[int]{value: i + 1})
+	}
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestAppendLogTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	size := writeTestLog(t, path)
+	if err := os.Truncate(path, size-3); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]int{}
+	n, end, err := replayAppendLog(path, StringCodec{}, DefaultCodec[int](),
+		func(key string, it item[int]) { data[key] = it.value }, nil)
+	if err != nil || n != 2 || data["a"] != 1 || data["b"] != 2 {
+		t.Fatalf("replay = %d, %v, data %v, want the two complete records", n, err, data)
+	}
+
+	dropped, err := trimAppendLog(path, end)
+	if err != nil || dropped != size-3-end {
+		t.Fatalf("trim dropped %d, %v, want %d", dropped, err, size-3-end)
+	}
+}
+
+func TestAppendLogChecksumMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	writeTestLog(t, path)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 修改第二条记录的校验和
+	first := 4 + int(raw[headerSize+3]) + 4
+	raw[headerSize+first+4+int(raw[headerSize+first+3])]++
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, end, err := replayAppendLog(path, StringCodec{}, DefaultCodec[int](), func(string, item[int]) {}, nil)
+	if !errors.Is(err, ErrCorruptSnapshot) || n != 1 || end != int64(headerSize+first) {
+		t.Fatalf("replay = %d, %d, %v, want 1 record ending at %d and ErrCorruptSnapshot", n, end, err, headerSize+first)
+	}
+}
+
+// 快照一直失败时，重启后追加的记录接在截断后的日志后面，下次恢复时不会丢失
+func TestRestoreTrimsLogWhenSnapshotFails(t *testing.T) {
+	dir := t.TempDir()
+	// Path 是目录，快照写入总是失败
+	path := filepath.Join(dir, "snapshot")
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	aof := path + ".aof"
+	size := writeTestLog(t, aof)
+	if err := os.Truncate(aof, size-3); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config[string, int]{Persist: PersistConfig[string, int]{Path: path, AppendLog: true, Keys: StringCodec{}}}
+	cache := NewLocalCache(cfg)
+	if _, found := cache.Get("c"); found {
+		t.Fatal("restored the truncated record")
+	}
+	cache.Set("d", 4, time.Hour)
+	cache.Close()
+
+	data := replayAll(t, aof)
+	if len(data) != 3 || data["a"] != 1 || data["b"] != 2 || data["d"] != 4 {
+		t.Fatalf("replayed %v, want a=1 b=2 d=4", data)
+	}
+}
+
+// 轮转期间写入的记录先留在内存中，finishRotate 之后写入新日志，顺序不变
+func TestAppendLogRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	l, err := openAppendLog(path, StringCodec{}, DefaultCodec[int](), NewFakeClock(epoch), discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.set("a", item[int]{value: 1})
+	l.set("b", item[int]{value: 2})
+	l.rotate()
+	l.set("a", item[int]{value: 3})
+	l.delete("b")
+	if err := l.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.finishRotate(); err != nil {
+		t.Fatal(err)
+	}
+	l.set("c", item[int]{value: 4})
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := replayAll(t, path)
+	if len(data) != 2 || data["a"] != 3 || data["c"] != 4 {
+		t.Fatalf("replayed %v, want a=3 c=4", data)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// hit 处理 GetOrLoad 的命中，按配置在 key 过期前提前刷新，错开同时过期的 key
+func (c *LocalCache[K, V]) hit(ctx context.Context, key K, it item[V], ttl time.Duration, load Loader[K, V]) V {
+	now := c.clock.Now().UnixNano()
+
+	switch {
+	case c.shouldRefreshEarly(it, now):
+		// 当前值还没过期，刷新失败时继续使用它
+		value, err := c.flight.do(ctx, key, c.reload(ctx, key, ttl, load))
+		if err == nil {
+			return value
+		}
+	case c.shouldRefreshAhead(key, it, now):
+		c.flight.start(key, c.reload(ctx, key, ttl, load))
+	}
+	return it.value
+}
+
+func (c *LocalCache[K, V]) reload(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) func() (V, error) {
+	ctx = context.WithoutCancel(ctx)
+	return func() (V, error) {
+		return c.load(ctx, key, ttl, load)
+	}
+}
+
+// XFetch：越接近过期、加载越慢，越有可能提前刷新
+func (c *LocalCache[K, V]) shouldRefreshEarly(it item[V], now int64) bool {
+	if c.cfg.EarlyRefreshBeta <= 0 || it.delta <= 0 {
+		return false
+	}
+
+	gap := float64(it.delta) * c.cfg.EarlyRefreshBeta * -math.Log(1-c.cfg.Rand())
+	return float64(now)+gap >= float64(it.expiration)
+}
+
+func (c *LocalCache[K, V]) shouldRefreshAhead(key K, it item[V], now int64) bool {
+	ratio := c.cfg.RefreshAhead
+	if ratio <= 0 && c.cfg.RefreshHotKeys && c.hot.isHot(key) {
+		ratio = defaultHotRefreshAhead
+	}
+	if ratio <= 0 {
+		return false
+	}
+	return float64(it.expiration-now) < ratio*float64(it.ttl)
+}
+
+func (c *LocalCache[K, V]) jitter(ttl time.Duration) time.Duration {
+	if c.cfg.Jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((c.cfg.Rand()*2-1)*c.cfg.Jitter*float64(ttl))
+}
@@ -0,0 +1,65 @@
+// The demo is for cache replicas sharing an L2
+
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 模拟两个副本共用一个 L2：一个副本加载过的数据另一个副本直接从 L2 读取，
+// 更新后其他副本的 L1 在 Invalidate 或 L1TTL 之后才会读到新值。
+// L1 使用手动推进的时钟，L2 的过期时间由 RESPServer 按真实时间计算
+func SimulateTieredCache() {
+	server, err := NewRESPServer("127.0.0.1:0")
+	if err != nil {
+		logger.Warn("start RESP server failed", "error", err)
+		return
+	}
+	defer server.Close()
+
+	client := NewRESPClient(server.Addr(), 0)
+	defer client.Close()
+
+	var queries atomic.Int64
+	load := func(ctx context.Context, key string) (string, error) {
+		queries.Add(1)
+		return queryFromDB(ctx, key)
+	}
+
+	clock := NewFakeClock(time.Now())
+	newReplica := func() *TieredCache[string, string] {
+		return NewTieredCache(TieredConfig[string, string]{
+			L2:     client,
+			Codec:  StringCodec{},
+			Prefix: "demo:",
+			L1TTL:  time.Second,
+			Clock:  clock,
+			Logger: logger,
+		})
+	}
+	a, b := newReplica(), newReplica()
+	ctx := context.Background()
+
+	for _, replica := range []struct {
+		name  string
+		cache *TieredCache[string, string]
+	}{{"a", a}, {"b", b}} {
+		value, err := replica.cache.GetOrLoad(ctx, "hotkey", 5*time.Second, load)
+		logger.Info("got value", "replica", replica.name, "key", "hotkey", "value", value, "error", err, "queries", queries.Load())
+	}
+
+	a.Set("hotkey", "Updated by a", 5*time.Second)
+	value, _ := b.Get("hotkey")
+	logger.Info("after update", "replica", "b", "key", "hotkey", "value", value)
+
+	b.Invalidate("hotkey")
+	value, _ = b.Get("hotkey")
+	logger.Info("after invalidate", "replica", "b", "key", "hotkey", "value", value)
+
+	a.Delete("hotkey")
+	clock.Add(1100 * time.Millisecond)
+	_, found := b.Get("hotkey")
+	logger.Info("after delete and L1 TTL", "replica", "b", "key", "hotkey", "found", found)
+}
@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Redis 限制单个 bulk string 最大 512MB
+const maxBulkLen = 512 << 20
+
+// 数组的长度单独限制，每个元素至少占用一个 interface 的空间，按 bulk string 的上限分配会占用数 GB 内存
+const maxArrayLen = 1024 * 1024
+
+const defaultRESPIdle = 8
+
+// RESPError 是服务端返回的错误回复
+type RESPError string
+
+func (e RESPError) Error() string {
+	return string(e)
+}
+
+// RemoteStore 是二级缓存的存储，key 不存在时 Get 返回 ErrNotFound，
+// 同时返回 key 剩余的有效期，没有过期时间时为 0
+type RemoteStore interface {
+	Get(ctx context.Context, key string) ([]byte, time.Duration, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// RESPClient 是使用 Redis RESP 协议的客户端，连接按需建立，空闲连接放回连接池复用
+type RESPClient struct {
+	addr   string
+	dialer net.Dialer
+	idle   chan *respConn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ RemoteStore = (*RESPClient)(nil)
+
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// 创建 RESP 客户端，maxIdle 是连接池保留的最大空闲连接数，小于等于 0 时使用 8
+func NewRESPClient(addr string, maxIdle int) *RESPClient {
+	if maxIdle <= 0 {
+		maxIdle = defaultRESPIdle
+	}
+	return &RESPClient{
+		addr: addr,
+		idle: make(chan *respConn, maxIdle),
+	}
+}
+
+// Get 在一次往返中发送 GET 和 PTTL，返回 key 的值和剩余的有效期，不存在时返回 ErrNotFound
+func (c *RESPClient) Get(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	replies, err := c.pipeline(ctx, []string{"GET", key}, []string{"PTTL", key})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, reply := range replies {
+		if respErr, ok := reply.(RESPError); ok {
+			return nil, 0, respErr
+		}
+	}
+
+	if replies[0] == nil {
+		return nil, 0, ErrNotFound
+	}
+	data, ok := replies[0].([]byte)
+	if !ok {
+		return nil, 0, fmt.Errorf("cache: unexpected GET reply %T", replies[0])
+	}
+	ms, ok := replies[1].(int64)
+	if !ok {
+		return nil, 0, fmt.Errorf("cache: unexpected PTTL reply %T", replies[1])
+	}
+	switch {
+	case ms == -1:
+		// 没有过期时间
+		return data, 0, nil
+	case ms < 0:
+		// GET 和 PTTL 之间过期了
+		return nil, 0, ErrNotFound
+	}
+	return data, max(time.Duration(ms)*time.Millisecond, time.Millisecond), nil
+}
+
+// Set 写入 key，ttl 大于 0 时按毫秒设置过期时间
+func (c *RESPClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Delete 删除 key，key 不存在时不返回错误
+func (c *RESPClient) Delete(ctx context.Context, key string) error {
+	_, err := c.Do(ctx, "DEL", key)
+	return err
+}
+
+// Do 发送一条命令并返回回复，回复按类型解析为 string、int64、[]byte、[]any 或 nil，
+// 错误回复返回 RESPError
+func (c *RESPClient) Do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if respErr, ok := replies[0].(RESPError); ok {
+		return nil, respErr
+	}
+	return replies[0], nil
+}
+
+// pipeline 在同一个连接上一次发送多条命令，按顺序返回回复，错误回复作为 RESPError 元素返回
+func (c *RESPClient) pipeline(ctx context.Context, cmds ...[]string) ([]any, error) {
+	rc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		rc.conn.SetDeadline(deadline)
+	} else {
+		rc.conn.SetDeadline(time.Time{})
+	}
+
+	replies, err := rc.do(cmds)
+	if err != nil {
+		// 网络错误后连接上可能残留半个回复，不能复用
+		rc.conn.Close()
+		return nil, err
+	}
+	c.put(rc)
+	return replies, nil
+}
+
+func (c *RESPClient) get(ctx context.Context) (*respConn, error) {
+	select {
+	case rc := <-c.idle:
+		return rc, nil
+	default:
+	}
+
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, net.ErrClosed
+	}
+
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &respConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}, nil
+}
+
+func (c *RESPClient) put(rc *respConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		rc.conn.Close()
+		return
+	}
+	select {
+	case c.idle <- rc:
+	default:
+		rc.conn.Close()
+	}
+}
+
+// Close 关闭空闲连接，正在使用的连接在命令完成后关闭
+func (c *RESPClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for {
+		select {
+		case rc := <-c.idle:
+			rc.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (rc *respConn) do(cmds [][]string) ([]any, error) {
+	for _, args := range cmds {
+		writeCommand(rc.w, args)
+	}
+	if err := rc.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, len(cmds))
+	for i := range replies {
+		reply, err := readReply(rc.r)
+		var respErr RESPError
+		if errors.As(err, &respErr) {
+			reply = respErr
+		} else if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// writeCommand 把命令编码为 bulk string 数组
+func writeCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		writeBulk(w, []byte(arg))
+	}
+}
+
+func writeBulk(w *bufio.Writer, data []byte) {
+	if data == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n", len(data))
+	w.Write(data)
+	w.WriteString("\r\n")
+}
+
+// readReply 读取一个 RESP 回复，错误回复作为 RESPError 返回
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("cache: empty RESP line")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, RESPError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 || n > maxBulkLen {
+			return nil, fmt.Errorf("cache: invalid bulk length %q", line[1:])
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if data[n] != '\r' || data[n+1] != '\n' {
+			return nil, errors.New("cache: bulk string not terminated by CRLF")
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 || n > maxArrayLen {
+			return nil, fmt.Errorf("cache: invalid array length %q", line[1:])
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			// 数组中的错误回复作为元素返回，不中断解析
+			item, err := readReply(r)
+			var respErr RESPError
+			if errors.As(err, &respErr) {
+				item = respErr
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("cache: unknown RESP type %q", line[0])
+	}
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, errors.New("cache: RESP line too long")
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("cache: RESP line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 没有设置过期时间的 key 使用的 TTL
+const noExpiration = 100 * 365 * 24 * time.Hour
+
+// RESPServer 是 Redis 的替身，只支持 PING、GET、SET（EX、PX、NX）、PTTL、DEL、EXISTS、INCR，
+// 以及 EVAL 执行 RESPClient 的锁脚本。数据保存在 LocalCache 中，
+// 用于在没有 Redis 的环境里演示和测试二级缓存和分布式锁
+type RESPServer struct {
+	ln   net.Listener
+	data *LocalCache[string, []byte]
+	// 和 Redis 一样串行执行命令，保证 SET NX、INCR 和脚本的原子性
+	exec sync.Mutex
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// 在 addr 上启动 RESP 服务，addr 为 "127.0.0.1:0" 时使用随机端口，通过 Addr 获取实际地址
+func NewRESPServer(addr string) (*RESPServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &RESPServer{
+		ln:    ln,
+		data:  NewLocalCache(Config[string, []byte]{JanitorInterval: time.Second}),
+		conns: make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 返回服务监听的地址
+func (s *RESPServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *RESPServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *RESPServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		args, ok := commandArgs(req)
+		if !ok {
+			w.WriteString("-ERR protocol error: expected array of bulk strings\r\n")
+			w.Flush()
+			return
+		}
+
+		s.exec.Lock()
+		s.execute(w, args)
+		s.exec.Unlock()
+		// 客户端流水线发送的命令处理完再一起写回
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func commandArgs(req any) ([][]byte, bool) {
+	items, ok := req.([]any)
+	if !ok || len(items) == 0 {
+		return nil, false
+	}
+	args := make([][]byte, len(items))
+	for i, item := range items {
+		if args[i], ok = item.([]byte); !ok {
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+func (s *RESPServer) execute(w *bufio.Writer, args [][]byte) {
+	switch cmd := strings.ToUpper(string(args[0])); {
+	case cmd == "PING" && len(args) == 1:
+		w.WriteString("+PONG\r\n")
+	case cmd == "GET" && len(args) == 2:
+		value, _ := s.data.Get(string(args[1]))
+		writeBulk(w, value)
+	case cmd == "SET" && len(args) >= 3:
+		ttl, nx, err := parseSetOptions(args[3:])
+		if err != "" {
+			w.WriteString("-ERR " + err + "\r\n")
+			return
+		}
+		if _, found := s.data.Get(string(args[1])); found && nx {
+			writeBulk(w, nil)
+			return
+		}
+		s.data.Set(string(args[1]), args[2], ttl)
+		w.WriteString("+OK\r\n")
+	case cmd == "PTTL" && len(args) == 2:
+		w.WriteString(":" + strconv.FormatInt(s.pttl(string(args[1])), 10) + "\r\n")
+	case cmd == "INCR" && len(args) == 2:
+		n, ok := s.incr(string(args[1]))
+		if !ok {
+			w.WriteString("-ERR value is not an integer or out of range\r\n")
+			return
+		}
+		w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+	case cmd == "EVAL" && len(args) >= 3:
+		numkeys, err := strconv.Atoi(string(args[2]))
+		if err != nil || numkeys < 1 || numkeys > len(args)-3 {
+			w.WriteString("-ERR invalid number of keys\r\n")
+			return
+		}
+		keys := make([]string, numkeys)
+		for i := range keys {
+			keys[i] = string(args[3+i])
+		}
+		s.eval(w, string(args[1]), keys, args[3+numkeys:])
+	case cmd == "DEL" && len(args) >= 2:
+		s.writeCount(w, args[1:], func(key string) bool {
+			_, found := s.data.Get(key)
+			s.data.Delete(key)
+			return found
+		})
+	case cmd == "EXISTS" && len(args) >= 2:
+		s.writeCount(w, args[1:], func(key string) bool {
+			_, found := s.data.Get(key)
+			return found
+		})
+	default:
+		w.WriteString("-ERR unknown command or wrong number of arguments for '" + cmd + "'\r\n")
+	}
+}
+
+func (s *RESPServer) writeCount(w *bufio.Writer, keys [][]byte, match func(key string) bool) {
+	n := 0
+	for _, key := range keys {
+		if match(string(key)) {
+			n++
+		}
+	}
+	w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+// parseSetOptions 解析 SET 的 EX、PX 和 NX 选项，出错时返回错误信息
+func parseSetOptions(opts [][]byte) (ttl time.Duration, nx bool, err string) {
+	ttl = noExpiration
+	for i := 0; i < len(opts); i++ {
+		switch opt := strings.ToUpper(string(opts[i])); opt {
+		case "NX":
+			nx = true
+		case "EX", "PX":
+			if i+1 == len(opts) {
+				return 0, false, "syntax error"
+			}
+			i++
+			unit := time.Millisecond
+			if opt == "EX" {
+				unit = time.Second
+			}
+			var ok bool
+			if ttl, ok = parseExpire(opts[i], unit); !ok {
+				return 0, false, "invalid expire time in 'set' command"
+			}
+		default:
+			return 0, false, "syntax error"
+		}
+	}
+	return ttl, nx, ""
+}
+
+// parseExpire 把以 unit 为单位的正整数转换为 TTL，超过 MaxInt64/unit 时乘法会溢出，拒绝这样的值；
+// 超过 noExpiration 的 TTL 按 noExpiration 处理，避免过期时间的纳秒数溢出
+func parseExpire(arg []byte, unit time.Duration) (time.Duration, bool) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return min(time.Duration(n)*unit, noExpiration), true
+}
+
+// eval 只支持 RESPClient 使用的加锁、比较后续期和比较后删除三个脚本
+func (s *RESPServer) eval(w *bufio.Writer, script string, keys []string, argv [][]byte) {
+	if script == acquireScript && len(keys) == 2 && len(argv) == 2 {
+		s.acquire(w, keys[0], keys[1], argv[0], argv[1])
+		return
+	}
+	if len(keys) != 1 || len(argv) == 0 {
+		w.WriteString("-NOSCRIPT only cache lock scripts are supported\r\n")
+		return
+	}
+
+	key := keys[0]
+	value, found := s.data.Get(key)
+	matched := found && string(value) == string(argv[0])
+
+	switch {
+	case script == renewScript && len(argv) == 2:
+		ttl, ok := parseExpire(argv[1], time.Millisecond)
+		if !ok {
+			w.WriteString("-ERR invalid expire time\r\n")
+			return
+		}
+		if matched {
+			s.data.Set(key, value, ttl)
+		}
+	case script == unlockScript && len(argv) == 1:
+		if matched {
+			s.data.Delete(key)
+		}
+	default:
+		w.WriteString("-NOSCRIPT only cache lock scripts are supported\r\n")
+		return
+	}
+
+	if matched {
+		w.WriteString(":1\r\n")
+	} else {
+		w.WriteString(":0\r\n")
+	}
+}
+
+// acquire 在 key 不存在时写入 value 并把 fence 加 1，返回新值，key 已存在时返回 0
+func (s *RESPServer) acquire(w *bufio.Writer, key, fence string, value, px []byte) {
+	ttl, ok := parseExpire(px, time.Millisecond)
+	if !ok {
+		w.WriteString("-ERR invalid expire time\r\n")
+		return
+	}
+	if _, found := s.data.Get(key); found {
+		w.WriteString(":0\r\n")
+		return
+	}
+
+	n, ok := s.incr(fence)
+	if !ok {
+		w.WriteString("-ERR value is not an integer or out of range\r\n")
+		return
+	}
+	s.data.Set(key, value, ttl)
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// pttl 返回 key 剩余的毫秒数，key 不存在时返回 -2，没有过期时间时返回 -1
+func (s *RESPServer) pttl(key string) int64 {
+	it, found := s.data.lookup(key)
+	switch {
+	case !found:
+		return -2
+	case it.ttl >= noExpiration:
+		return -1
+	}
+	return max((it.expiration-s.data.clock.Now().UnixNano())/int64(time.Millisecond), 0)
+}
+
+// incr 把 key 中的整数加 1，key 的值不是整数时返回 false
+func (s *RESPServer) incr(key string) (int64, bool) {
+	n := int64(0)
+	if value, found := s.data.Get(key); found {
+		var err error
+		if n, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return 0, false
+		}
+	}
+	n++
+	s.data.Set(key, []byte(strconv.FormatInt(n, 10)), noExpiration)
+	return n, true
+}
+
+// Close 停止监听并断开所有连接
+func (s *RESPServer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.ln.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.data.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startRESP 在随机端口上启动 RESPServer 并返回连接它的客户端
+func startRESP(t *testing.T) *RESPClient {
+	t.Helper()
+	server, err := NewRESPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewRESPClient(server.Addr(), 2)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client
+}
+
+func TestReadReplyArrayLength(t *testing.T) {
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"*2\r\n$1\r\na\r\n:1\r\n", true},
+		{"*-1\r\n", true},
+		{"*1048577\r\n", false},
+		{"*536870912\r\n", false},
+		{"*-2\r\n", false},
+	}
+
+	for _, tt := range tests {
+		_, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+		if (err == nil) != tt.ok {
+			t.Errorf("readReply(%q) error = %v, want ok %v", tt.input, err, tt.ok)
+		}
+	}
+}
+
+func TestParseSetOptions(t *testing.T) {
+	tests := []struct {
+		opts []string
+		ttl  time.Duration
+		nx   bool
+		ok   bool
+	}{
+		{nil, noExpiration, false, true},
+		{[]string{"PX", "1500"}, 1500 * time.Millisecond, false, true},
+		{[]string{"ex", "2", "nx"}, 2 * time.Second, true, true},
+		{[]string{"EX", "0"}, 0, false, false},
+		{[]string{"PX"}, 0, false, false},
+		// 乘以单位之后溢出
+		{[]string{"EX", "9223372036854775807"}, 0, false, false},
+		{[]string{"PX", "9223372036855"}, 0, false, false},
+		// 不溢出但超过 noExpiration
+		{[]string{"PX", "9223372036854"}, noExpiration, false, true},
+	}
+
+	for _, tt := range tests {
+		opts := make([][]byte, len(tt.opts))
+		for i, opt := range tt.opts {
+			opts[i] = []byte(opt)
+		}
+		ttl, nx, err := parseSetOptions(opts)
+		if (err == "") != tt.ok || ttl != tt.ttl || nx != tt.nx {
+			t.Errorf("parseSetOptions(%q) = %v, %v, %q, want %v, %v, ok %v", tt.opts, ttl, nx, err, tt.ttl, tt.nx, tt.ok)
+		}
+	}
+}
+
+func TestRESPClientServer(t *testing.T) {
+	client := startRESP(t)
+	ctx := context.Background()
+
+	if reply, err := client.Do(ctx, "PING"); err != nil || reply != "PONG" {
+		t.Fatalf("PING = %v, %v", reply, err)
+	}
+	if _, _, err := client.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get missing key = %v, want ErrNotFound", err)
+	}
+
+	if err := client.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	data, remaining, err := client.Get(ctx, "key")
+	if err != nil || string(data) != "value" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+	if remaining <= 59*time.Second || remaining > time.Minute {
+		t.Fatalf("remaining TTL = %v, want about a minute", remaining)
+	}
+
+	if err := client.Set(ctx, "forever", []byte{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if data, remaining, err := client.Get(ctx, "forever"); err != nil || len(data) != 0 || remaining != 0 {
+		t.Fatalf("Get without TTL = %q, %v, %v, want empty value and no expiration", data, remaining, err)
+	}
+
+	if reply, err := client.Do(ctx, "SET", "key", "other", "NX"); err != nil || reply != nil {
+		t.Fatalf("SET NX on existing key = %v, %v, want nil", reply, err)
+	}
+	if err := client.Delete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := client.Do(ctx, "EXISTS", "key", "forever"); err != nil || reply != int64(1) {
+		t.Fatalf("EXISTS = %v, %v, want 1", reply, err)
+	}
+
+	// 错误回复不影响同一个连接上的后续命令
+	var respErr RESPError
+	if _, err := client.Do(ctx, "SET", "key", "value", "PX", "0"); !errors.As(err, &respErr) {
+		t.Fatalf("SET PX 0 = %v, want RESPError", err)
+	}
+	if _, err := client.Do(ctx, "FLUSHALL"); !errors.As(err, &respErr) {
+		t.Fatalf("unknown command = %v, want RESPError", err)
+	}
+	if reply, err := client.Do(ctx, "INCR", "counter"); err != nil || reply != int64(1) {
+		t.Fatalf("INCR = %v, %v, want 1", reply, err)
+	}
+}
+
+func TestTieredL1TTLFollowsL2(t *testing.T) {
+	client := startRESP(t)
+	ctx := context.Background()
+	clock := NewFakeClock(epoch)
+	l1 := NewLocalCache(Config[string, string]{BreakdownLock: true, Clock: clock})
+	defer l1.Close()
+	cache := NewTieredCache(TieredConfig[string, string]{L1: l1, L2: client, L1TTL: 10 * time.Second})
+	defer cache.Close()
+
+	// 其他副本写入的数据在 L2 中只剩 2 秒
+	for _, key := range []string{"get", "load"} {
+		data, _ := cache.cfg.Codec.Encode("remote")
+		if err := client.Set(ctx, key, data, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if value, found := cache.Get("get"); !found || value != "remote" {
+		t.Fatalf("Get = %q, %v, want the L2 value", value, found)
+	}
+	load := func(ctx context.Context, key string) (string, error) {
+		t.Fatal("loaded a key that is in L2")
+		return "", nil
+	}
+	if value, err := cache.GetOrLoad(ctx, "load", time.Minute, load); err != nil || value != "remote" {
+		t.Fatalf("GetOrLoad = %q, %v, want the L2 value", value, err)
+	}
+
+	// L1 中的副本和 L2 一起过期，而不是再活 L1TTL
+	clock.Add(2 * time.Second)
+	for _, key := range []string{"get", "load"} {
+		if _, found := l1.Get(key); found {
+			t.Errorf("L1 kept %q after its L2 TTL", key)
+		}
+	}
+
+	// L2 中的数据还有很长的有效期时，L1 仍然使用 L1TTL
+	cache.Set("long", "value", time.Hour)
+	l1.Delete("long")
+	if _, found := cache.Get("long"); !found {
+		t.Fatal("Get missed a key in L2")
+	}
+	clock.Add(10*time.Second + time.Millisecond)
+	if _, found := l1.Get("long"); found {
+		t.Fatal("L1 kept a key longer than L1TTL")
+	}
+}
+
+func TestTieredGetOrLoadFillsBothLevels(t *testing.T) {
+	client := startRESP(t)
+	ctx := context.Background()
+	cache := NewTieredCache(TieredConfig[string, int]{L2: client})
+	defer cache.Close()
+
+	loads := 0
+	load := func(ctx context.Context, key string) (int, error) {
+		loads++
+		return 42, nil
+	}
+	if value, err := cache.GetOrLoad(ctx, "key", time.Minute, load); err != nil || value != 42 {
+		t.Fatalf("GetOrLoad = %d, %v", value, err)
+	}
+
+	// 另一个副本的 L1 为空，从 L2 读取，不访问数据源
+	other := NewTieredCache(TieredConfig[string, int]{L2: client})
+	defer other.Close()
+	if value, err := other.GetOrLoad(ctx, "key", time.Minute, load); err != nil || value != 42 || loads != 1 {
+		t.Fatalf("GetOrLoad on another replica = %d, %v after %d loads, want 42 from L2", value, err, loads)
+	}
+
+	cache.Delete("key")
+	if _, _, err := client.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("L2 Get after Delete = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,63 @@
+// The demo is for restoring the cache after a restart
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// 模拟进程重启：正常退出时 Close 写快照，崩溃时从快照和追加日志恢复，
+// 重启后的请求直接命中缓存，不会全部落到数据库
+func SimulateRestart() {
+	dir, err := os.MkdirTemp("", "cache-snapshot")
+	if err != nil {
+		logger.Warn("create snapshot dir failed", "error", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var queries atomic.Int64
+	load := func(ctx context.Context, key string) (string, error) {
+		queries.Add(1)
+		return "Data from DB for " + key, nil
+	}
+
+	cfg := Config[string, string]{
+		BreakdownLock: true,
+		Persist: PersistConfig[string, string]{
+			Path:      filepath.Join(dir, "cache.snapshot"),
+			Interval:  time.Minute,
+			AppendLog: true,
+		},
+		Logger: logger,
+	}
+	ctx := context.Background()
+	warm := func(cache *LocalCache[string, string]) int64 {
+		before := queries.Load()
+		for i := 0; i < 100; i++ {
+			cache.GetOrLoad(ctx, fmt.Sprintf("key-%d", i), time.Minute, load)
+		}
+		return queries.Load() - before
+	}
+
+	cache := NewLocalCache(cfg)
+	logger.Info("first start", "queries", warm(cache))
+	cache.Close()
+
+	cache = NewLocalCache(cfg)
+	logger.Info("after graceful restart", "entries", cache.Len(), "queries", warm(cache))
+
+	// 崩溃前的写入只在追加日志里，等待日志刷盘后不调用 Close 直接重启
+	cache.Set("key-100", "written before crash", time.Minute)
+	time.Sleep(appendLogSyncInterval + 100*time.Millisecond)
+
+	restarted := NewLocalCache(cfg)
+	defer restarted.Close()
+	value, found := restarted.Get("key-100")
+	logger.Info("after crash", "entries", restarted.Len(), "key", "key-100", "value", value, "found", found)
+}
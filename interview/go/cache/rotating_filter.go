@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotatingFilter 定期从数据源重新构建过滤器并替换当前的过滤器，
+// 清除已经从数据源删除的 key，并按最新的 key 数量重新确定容量
+type RotatingFilter struct {
+	newFilter func(expectedItems uint) MembershipFilter
+	src       KeySource
+	clock     Clock
+
+	mu      sync.RWMutex
+	current MembershipFilter
+	// 重建期间 Add 的 key，替换前补到新的过滤器中，避免丢失
+	pending []string
+	// 上一次重建时数据源中 key 的数量
+	items int
+
+	rotating  sync.Mutex
+	rotations atomic.Uint64
+	failures  atomic.Uint64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+var (
+	_ MembershipFilter = (*RotatingFilter)(nil)
+	_ FPREstimator     = (*RotatingFilter)(nil)
+)
+
+// 创建定期重建的过滤器，先同步构建一次，interval 大于 0 时在后台按 clock 每隔 interval 重建，
+// clock 为空时使用系统时钟。newFilter 按预计的 key 数量创建新的过滤器，预计数量是上一次 key 数量的两倍
+func NewRotatingFilter(ctx context.Context, src KeySource, interval time.Duration, clock Clock, newFilter func(expectedItems uint) MembershipFilter) (*RotatingFilter, error) {
+	f := &RotatingFilter{
+		newFilter: newFilter,
+		src:       src,
+		clock:     clockOr(clock),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if err := f.Rotate(ctx); err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		close(f.done)
+		return f, nil
+	}
+	// 在返回前创建 Ticker，之后推进的时间都会计入第一个周期
+	go f.run(f.clock.NewTicker(interval))
+	return f, nil
+}
+
+func (f *RotatingFilter) run(ticker Ticker) {
+	defer close(f.done)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-f.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ticker.Chan():
+			// 重建失败时继续使用旧的过滤器，等待下一个周期
+			f.Rotate(ctx)
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Rotate 立即从数据源重建过滤器
+func (f *RotatingFilter) Rotate(ctx context.Context) error {
+	f.rotating.Lock()
+	defer f.rotating.Unlock()
+
+	f.mu.Lock()
+	expected := uint(max(f.items*2, 1))
+	f.pending = []string{}
+	f.mu.Unlock()
+
+	next := f.newFilter(expected)
+	n, err := WarmFilter(ctx, next, f.src)
+	if err != nil {
+		f.mu.Lock()
+		f.pending = nil
+		f.mu.Unlock()
+
+		f.failures.Add(1)
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range f.pending {
+		next.Add(key)
+	}
+	f.pending = nil
+	f.current = next
+	f.items = n
+	f.rotations.Add(1)
+	return nil
+}
+
+func (f *RotatingFilter) Add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.current.Add(key)
+	if f.pending != nil {
+		f.pending = append(f.pending, key)
+	}
+}
+
+func (f *RotatingFilter) Test(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.current.Test(key)
+}
+
+// Remove 在当前过滤器支持删除时删除 key，否则等待下一次重建时清除
+func (f *RotatingFilter) Remove(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if r, ok := f.current.(RemovableFilter); ok {
+		return r.Remove(key)
+	}
+	return false
+}
+
+// EstimatedFPR 返回当前过滤器估算的误判率，过滤器不支持估算时返回 0
+func (f *RotatingFilter) EstimatedFPR() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if e, ok := f.current.(FPREstimator); ok {
+		return e.EstimatedFPR()
+	}
+	return 0
+}
+
+// Rotations 返回成功重建的次数
+func (f *RotatingFilter) Rotations() uint64 {
+	return f.rotations.Load()
+}
+
+// Failures 返回重建失败的次数
+func (f *RotatingFilter) Failures() uint64 {
+	return f.failures.Load()
+}
+
+// Close 停止后台重建，可以重复调用
+func (f *RotatingFilter) Close() error {
+	f.once.Do(func() {
+		close(f.stop)
+	})
+	<-f.done
+	return nil
+}
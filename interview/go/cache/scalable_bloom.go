@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/bits-and-blooms/bloom"
+)
+
+const (
+	// 每个新分片的容量是上一个的 scalableGrowth 倍
+	scalableGrowth = 2
+	// 每个新分片的误判率是上一个的 scalableTightening 倍，总误判率不超过 fpRate / (1 - 0.5)
+	scalableTightening = 0.5
+)
+
+// ScalableBloomFilter 在当前分片加满后追加容量更大、误判率更低的新分片，
+// key 的数量超出预计时误判率仍然有上限
+type ScalableBloomFilter struct {
+	mu     sync.RWMutex
+	slices []*bloomSlice
+}
+
+type bloomSlice struct {
+	bf       *bloom.BloomFilter
+	n        uint
+	capacity uint
+	fpRate   float64
+}
+
+var (
+	_ MembershipFilter = (*ScalableBloomFilter)(nil)
+	_ FPREstimator     = (*ScalableBloomFilter)(nil)
+)
+
+// 创建可扩容的布隆过滤器，initialItems 和 fpRate 是第一个分片的容量和误判率
+func NewScalableBloomFilter(initialItems uint, fpRate float64) *ScalableBloomFilter {
+	f := &ScalableBloomFilter{}
+	f.grow(max(initialItems, 1), fpRate)
+	return f
+}
+
+func (f *ScalableBloomFilter) grow(capacity uint, fpRate float64) {
+	f.slices = append(f.slices, &bloomSlice{
+		bf:       bloom.NewWithEstimates(capacity, fpRate),
+		capacity: capacity,
+		fpRate:   fpRate,
+	})
+}
+
+func (f *ScalableBloomFilter) Add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 已经存在的 key 不占用新分片的容量
+	if f.test(key) {
+		return
+	}
+
+	last := f.slices[len(f.slices)-1]
+	if last.n >= last.capacity {
+		f.grow(last.capacity*scalableGrowth, last.fpRate*scalableTightening)
+		last = f.slices[len(f.slices)-1]
+	}
+
+	last.bf.AddString(key)
+	last.n++
+}
+
+func (f *ScalableBloomFilter) Test(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.test(key)
+}
+
+func (f *ScalableBloomFilter) test(key string) bool {
+	for _, s := range f.slices {
+		if s.bf.TestString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// EstimatedFPR 估算当前的误判率，任意一个分片误判即误判
+func (f *ScalableBloomFilter) EstimatedFPR() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	pass := 1.0
+	for _, s := range f.slices {
+		pass *= 1 - bloomFPR(s.bf.Cap(), s.bf.K(), s.n)
+	}
+	return 1 - pass
+}
+
+// Slices 返回当前分片的数量
+func (f *ScalableBloomFilter) Slices() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return len(f.slices)
+}
@@ -0,0 +1,85 @@
+// The demo is for typed values and their encoded size
+
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 模拟缓存的结构体，MarshalBinary 按 protobuf 的方式手写编码
+type user struct {
+	ID    int64
+	Name  string
+	Email string
+	Tags  []string
+}
+
+func (u user) MarshalBinary() ([]byte, error) {
+	buf := binary.AppendVarint(nil, u.ID)
+	for _, s := range append([]string{u.Name, u.Email}, u.Tags...) {
+		buf = binary.AppendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf, nil
+}
+
+func (u *user) UnmarshalBinary(data []byte) error {
+	id, n := binary.Varint(data)
+	if n <= 0 {
+		return errors.New("invalid user id")
+	}
+	data = data[n:]
+
+	var fields []string
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return errors.New("invalid user field")
+		}
+		fields = append(fields, string(data[n:n+int(size)]))
+		data = data[n+int(size):]
+	}
+	if len(fields) < 2 {
+		return errors.New("missing user fields")
+	}
+	*u = user{ID: id, Name: fields[0], Email: fields[1], Tags: fields[2:]}
+	return nil
+}
+
+// 比较三种编码的大小，并按编码后的大小限制缓存的总字节数
+func SimulateCodecs() {
+	u := user{ID: 42, Name: "gopher", Email: "gopher@example.com", Tags: []string{"admin", "beta"}}
+
+	codecs := []struct {
+		name  string
+		codec Codec[user]
+	}{
+		{"gob", GobCodec[user]{}},
+		{"json", JSONCodec[user]{}},
+		{"binary", BinaryCodec[user]{}},
+	}
+	for _, c := range codecs {
+		data, err := c.codec.Encode(u)
+		if err != nil {
+			logger.Warn("encode failed", "codec", c.name, "error", err)
+			continue
+		}
+		decoded, err := c.codec.Decode(data)
+		logger.Info("encoded", "codec", c.name, "bytes", len(data), "roundtrip", err == nil && decoded.Email == u.Email)
+	}
+
+	cache := NewLocalCache(Config[string, user]{
+		MaxBytes: 1024,
+		Sizer:    CodecSizer[string](BinaryCodec[user]{}),
+		Logger:   logger,
+	})
+	for i := 0; i < 100; i++ {
+		u.ID = int64(i)
+		cache.Set(fmt.Sprintf("user-%d", i), u, time.Minute)
+	}
+	stats := cache.Stats()
+	logger.Info("byte bounded cache", "entries", stats.Size, "bytes", stats.Bytes, "evictions", stats.Evictions)
+}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"time"
+)
+
+const defaultShards = 16
+
+// ShardedCache 按 key 的哈希把数据分散到多个 LocalCache，每个分片有独立的锁，
+// 减少高并发下单个读写锁的竞争
+type ShardedCache[K comparable, V any] struct {
+	cfg       Config[K, V]
+	shards    []*LocalCache[K, V]
+	persister *persister[K, V]
+}
+
+var _ Cache[string, string] = (*ShardedCache[string, string])(nil)
+
+// 创建分片缓存，shards 小于等于 0 时使用 16 个分片。
+// MaxEntries、MaxBytes 和 MaxNegative 平均分给每个分片，淘汰策略实例不能在分片之间共享，
+// 所以忽略 cfg.Policy，改为用 newPolicy 按分片的容量为每个分片创建，为空时使用 LRU
+func NewShardedCache[K comparable, V any](shards int, cfg Config[K, V], newPolicy func(capacity int) EvictionPolicy[K]) *ShardedCache[K, V] {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+
+	c := &ShardedCache[K, V]{
+		cfg:    cfg,
+		shards: make([]*LocalCache[K, V], shards),
+	}
+
+	// 所有分片写入同一个快照，恢复时按 key 重新分片
+	shardCfg := cfg
+	shardCfg.Policy = nil
+	shardCfg.Persist = PersistConfig[K, V]{}
+	shardCfg.WriteBehind = WriteBehindConfig[K, V]{}
+	// 限流和熔断针对同一个数据源，所有分片共用
+	shardCfg.MaxConcurrentLoads, shardCfg.LoadRate, shardCfg.BreakerFailures = 0, 0, 0
+	if cfg.MaxEntries > 0 {
+		shardCfg.MaxEntries = max(cfg.MaxEntries/shards, 1)
+	}
+	if cfg.MaxBytes > 0 {
+		shardCfg.MaxBytes = max(cfg.MaxBytes/int64(shards), 1)
+	}
+	if cfg.MaxNegative > 0 {
+		shardCfg.MaxNegative = max(cfg.MaxNegative/shards, 1)
+	}
+
+	clock := clockOr(cfg.Clock)
+	guard := newLoadGuard(cfg, clock, cfg.Logger)
+	events := newEventHub[K, V](clock)
+	for i := range c.shards {
+		if newPolicy != nil {
+			shardCfg.Policy = newPolicy(shardCfg.MaxEntries)
+		}
+		c.shards[i] = NewLocalCache(shardCfg)
+		c.shards[i].guard = guard
+		c.shards[i].events = events
+	}
+	if cfg.Persist.Path != "" {
+		c.persist(cfg.Persist)
+	}
+	// 恢复完成后再开启 write-behind，重放的数据不写回后端存储
+	if cfg.WriteBehind.Store != nil {
+		writer := newWriteBehind(cfg.WriteBehind, clock, cfg.Logger)
+		for _, shard := range c.shards {
+			shard.writer = writer
+		}
+	}
+	return c
+}
+
+func (c *ShardedCache[K, V]) shard(key K) *LocalCache[K, V] {
+	return c.shards[hashKey(key)%uint64(len(c.shards))]
+}
+
+// 获取缓存数据
+func (c *ShardedCache[K, V]) Get(key K) (V, bool) {
+	return c.shard(key).Get(key)
+}
+
+// 设置缓存数据
+func (c *ShardedCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.shard(key).Set(key, value, ttl)
+}
+
+// 删除缓存数据
+func (c *ShardedCache[K, V]) Delete(key K) {
+	c.shard(key).Delete(key)
+}
+
+// Invalidate 和 LocalCache.Invalidate 相同
+func (c *ShardedCache[K, V]) Invalidate(key K) {
+	c.shard(key).Invalidate(key)
+}
+
+// 获取缓存数据，未命中时通过 load 从数据源加载并回填缓存
+func (c *ShardedCache[K, V]) GetOrLoad(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error) {
+	res, err := c.Fetch(ctx, key, ttl, load)
+	return res.Value, err
+}
+
+// Fetch 和 LocalCache.Fetch 相同
+func (c *ShardedCache[K, V]) Fetch(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (Result[V], error) {
+	return c.shard(key).Fetch(ctx, key, ttl, load)
+}
+
+// Purge 和 LocalCache.Purge 相同
+func (c *ShardedCache[K, V]) Purge(key K) {
+	c.shard(key).Purge(key)
+}
+
+// Len 返回所有分片的数据条数之和
+func (c *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// JanitorStats 返回所有分片后台清理统计的汇总
+func (c *ShardedCache[K, V]) JanitorStats() JanitorStats {
+	var stats JanitorStats
+	for _, shard := range c.shards {
+		s := shard.JanitorStats()
+		stats.Runs += s.Runs
+		stats.Sampled += s.Sampled
+		stats.Reclaimed += s.Reclaimed
+		stats.Compactions += s.Compactions
+	}
+	return stats
+}
+
+// Close 停止所有分片的后台清理，开启 write-behind 时写完所有修改，开启持久化时写最后一次快照
+func (c *ShardedCache[K, V]) Close() error {
+	err := c.shards[0].writer.close()
+	if c.persister != nil {
+		err = errors.Join(err, c.persister.close())
+	}
+	for _, shard := range c.shards {
+		shard.Close()
+	}
+	return err
+}
+
+// hashKey 计算 key 的 FNV-1a 哈希，常见的整数类型直接混淆，避免格式化成字符串
+func hashKey[K comparable](key K) uint64 {
+	var x uint64
+	switch k := any(key).(type) {
+	case string:
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		return h.Sum64()
+	case int:
+		x = uint64(k)
+	case int64:
+		x = uint64(k)
+	case int32:
+		x = uint64(k)
+	case uint:
+		x = uint64(k)
+	case uint64:
+		x = k
+	case uint32:
+		x = uint64(k)
+	default:
+		h := fnv.New64a()
+		h.Write([]byte(keyString(key)))
+		return h.Sum64()
+	}
+
+	// 让连续的整数均匀分布到各个分片
+	return mix64(x)
+}
+
+// mix64 是 splitmix64 的最后一步，让哈希的每一位都依赖输入的所有位
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+const benchKeys = 1000
+
+// 对比单锁 LocalCache 和 ShardedCache 的吞吐，读写比例 9:1，1000 个 key。
+// 使用 go test -bench . -cpu 1,2,4,8,16 在不同的 GOMAXPROCS 下运行
+func BenchmarkLocalCache(b *testing.B) {
+	benchmarkCache(b, NewLocalCache(Config[int, int]{}))
+}
+
+func BenchmarkShardedCache(b *testing.B) {
+	benchmarkCache(b, NewShardedCache(16, Config[int, int]{}, nil))
+}
+
+// 开启热点 key 统计后每次读取都会记录访问
+func BenchmarkLocalCacheHotKeys(b *testing.B) {
+	benchmarkCache(b, NewLocalCache(Config[int, int]{HotKeys: 16}))
+}
+
+func benchmarkCache(b *testing.B, cache Cache[int, int]) {
+	for i := 0; i < benchKeys; i++ {
+		cache.Set(i, i, time.Hour)
+	}
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := i % benchKeys
+			if i%10 == 0 {
+				cache.Set(key, i, time.Hour)
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func TestShardedCacheSpreadsKeys(t *testing.T) {
+	cache := NewShardedCache(4, Config[int, int]{}, nil)
+	defer cache.Close()
+
+	for i := 0; i < 400; i++ {
+		cache.Set(i, i, time.Hour)
+	}
+	for i := 0; i < 400; i++ {
+		if value, found := cache.Get(i); !found || value != i {
+			t.Fatalf("Get(%d) = %d, %v", i, value, found)
+		}
+	}
+	for i, shard := range cache.shards {
+		if shard.Len() == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+	}
+}
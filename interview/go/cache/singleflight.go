@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// call 表示一次正在进行的加载，等待者共享它的结果
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// flight 合并同一个 key 的并发加载，不同 key 之间的加载互不阻塞
+type flight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// do 对同一个 key 同一时刻只执行一次 fn，其余调用者等待并共享结果。
+// fn 在独立的 goroutine 中执行，调用者的 ctx 取消只会放弃等待，不会中断加载
+func (f *flight[K, V]) do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	c := f.start(key, fn)
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// start 在 key 没有正在进行的加载时启动 fn，不等待结果
+func (f *flight[K, V]) start(key K, fn func() (V, error)) *call[V] {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.calls == nil {
+		f.calls = make(map[K]*call[V])
+	}
+	c, ok := f.calls[key]
+	if !ok {
+		c = &call[V]{done: make(chan struct{})}
+		f.calls[key] = c
+		go f.run(key, c, fn)
+	}
+	return c
+}
+
+func (f *flight[K, V]) run(key K, c *call[V], fn func() (V, error)) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+}
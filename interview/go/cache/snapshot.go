@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 快照和追加日志的格式：
+//
+//	文件头  magic(4) | version(2) | reserved(2) | created(8) | crc(4)
+//	记录    length(4) | payload | crc(4)
+//	快照尾  length(4) = 0 | count(8) | crc(4)
+//
+// 整数都是大端，crc 是 CRC-32C。快照必须以快照尾结束，追加日志没有快照尾，
+// 最后一条记录不完整时认为是写到一半崩溃，忽略这条记录
+const (
+	snapshotMagic   = "LCSN"
+	appendLogMagic  = "LCAL"
+	snapshotVersion = 1
+
+	headerSize    = 20
+	maxRecordSize = 64 << 20
+)
+
+const (
+	flagNegative byte = 1 << iota
+)
+
+// ErrCorruptSnapshot 表示快照或者追加日志的格式或校验和不正确
+var ErrCorruptSnapshot = errors.New("cache: corrupt snapshot")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type snapshotEntry[K comparable, V any] struct {
+	key K
+	it  item[V]
+}
+
+func writeHeader(w io.Writer, magic string, created time.Time) error {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint16(header[4:], snapshotVersion)
+	binary.BigEndian.PutUint64(header[8:], uint64(created.UnixNano()))
+	binary.BigEndian.PutUint32(header[16:], crc32.Checksum(header[:16], crcTable))
+	_, err := w.Write(header)
+	return err
+}
+
+func readHeader(r io.Reader, magic string) (time.Time, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return time.Time{}, fmt.Errorf("%w: read header: %v", ErrCorruptSnapshot, err)
+	}
+	if string(header[:4]) != magic {
+		return time.Time{}, fmt.Errorf("%w: bad magic %q", ErrCorruptSnapshot, header[:4])
+	}
+	if crc32.Checksum(header[:16], crcTable) != binary.BigEndian.Uint32(header[16:]) {
+		return time.Time{}, fmt.Errorf("%w: header checksum mismatch", ErrCorruptSnapshot)
+	}
+	if version := binary.BigEndian.Uint16(header[4:]); version != snapshotVersion {
+		return time.Time{}, fmt.Errorf("cache: unsupported snapshot version %d", version)
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(header[8:]))), nil
+}
+
+func writeRecord(w io.Writer, payload []byte) error {
+	frame := make([]byte, 4+len(payload)+4)
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	binary.BigEndian.PutUint32(frame[4+len(payload):], crc32.Checksum(payload, crcTable))
+	_, err := w.Write(frame)
+	return err
+}
+
+// readRecord 读取一条记录，读到快照尾时返回 nil。文件在记录边界结束时返回 io.EOF，
+// 在记录中间结束时返回 io.ErrUnexpectedEOF
+func readRecord(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n > maxRecordSize {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrCorruptSnapshot, n)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	frame := make([]byte, n+4)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	payload := frame[:n]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(frame[n:]) {
+		return nil, fmt.Errorf("%w: record checksum mismatch", ErrCorruptSnapshot)
+	}
+	return payload, nil
+}
+
+// appendEntry 把一条数据编码为 flags(1) | expiration(8) | ttl(8) | delta(8) | key 长度 | key | value
+func appendEntry[K comparable, V any](buf []byte, keys Codec[K], values Codec[V], key K, it item[V]) ([]byte, error) {
+	k, err := keys.Encode(key)
+	if err != nil {
+		return nil, err
+	}
+	var v []byte
+	var flags byte
+	if it.negative {
+		flags |= flagNegative
+	} else if v, err = values.Encode(it.value); err != nil {
+		return nil, err
+	}
+
+	buf = append(buf, flags)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(it.expiration))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(it.ttl))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(it.delta))
+	buf = binary.AppendUvarint(buf, uint64(len(k)))
+	buf = append(buf, k...)
+	return append(buf, v...), nil
+}
+
+func decodeEntry[K comparable, V any](data []byte, keys Codec[K], values Codec[V]) (K, item[V], error) {
+	var key K
+	var it item[V]
+
+	if len(data) < 25 {
+		return key, it, fmt.Errorf("%w: short entry", ErrCorruptSnapshot)
+	}
+	flags := data[0]
+	it.expiration = int64(binary.BigEndian.Uint64(data[1:]))
+	it.ttl = time.Duration(binary.BigEndian.Uint64(data[9:]))
+	it.delta = time.Duration(binary.BigEndian.Uint64(data[17:]))
+	data = data[25:]
+
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return key, it, fmt.Errorf("%w: bad key length", ErrCorruptSnapshot)
+	}
+	key, err := keys.Decode(data[size : size+int(n)])
+	if err != nil {
+		return key, it, err
+	}
+	data = data[size+int(n):]
+
+	if flags&flagNegative != 0 {
+		it.negative = true
+		return key, it, nil
+	}
+	it.value, err = values.Decode(data)
+	return key, it, err
+}
+
+func writeSnapshot[K comparable, V any](w io.Writer, created time.Time, entries []snapshotEntry[K, V], keys Codec[K], values Codec[V]) error {
+	bw := bufio.NewWriter(w)
+	if err := writeHeader(bw, snapshotMagic, created); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, e := range entries {
+		var err error
+		if buf, err = appendEntry(buf[:0], keys, values, e.key, e.it); err != nil {
+			return err
+		}
+		if err := writeRecord(bw, buf); err != nil {
+			return err
+		}
+	}
+
+	trailer := make([]byte, 16)
+	binary.BigEndian.PutUint64(trailer[4:], uint64(len(entries)))
+	binary.BigEndian.PutUint32(trailer[12:], crc32.Checksum(trailer[4:12], crcTable))
+	if _, err := bw.Write(trailer); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// readSnapshot 读取并校验整个快照，任何错误都不返回部分数据
+func readSnapshot[K comparable, V any](r io.Reader, keys Codec[K], values Codec[V]) ([]snapshotEntry[K, V], error) {
+	br := bufio.NewReader(r)
+	if _, err := readHeader(br, snapshotMagic); err != nil {
+		return nil, err
+	}
+
+	var entries []snapshotEntry[K, V]
+	for {
+		payload, err := readRecord(br)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: truncated", ErrCorruptSnapshot)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			break
+		}
+
+		key, it, err := decodeEntry(payload, keys, values)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, snapshotEntry[K, V]{key, it})
+	}
+
+	trailer := make([]byte, 12)
+	if _, err := io.ReadFull(br, trailer); err != nil {
+		return nil, fmt.Errorf("%w: truncated", ErrCorruptSnapshot)
+	}
+	count := binary.BigEndian.Uint64(trailer)
+	if crc32.Checksum(trailer[:8], crcTable) != binary.BigEndian.Uint32(trailer[8:]) || count != uint64(len(entries)) {
+		return nil, fmt.Errorf("%w: trailer mismatch", ErrCorruptSnapshot)
+	}
+	return entries, nil
+}
+
+// writeSnapshotFile 先写临时文件再重命名，避免重启时读到写了一半的快照
+func writeSnapshotFile[K comparable, V any](path string, created time.Time, entries []snapshotEntry[K, V], keys Codec[K], values Codec[V]) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeSnapshot(tmp, created, entries, keys, values); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readSnapshotFile[K comparable, V any](path string, keys Codec[K], values Codec[V]) ([]snapshotEntry[K, V], error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries, err := readSnapshot(file, keys, values)
+	if err != nil {
+		return nil, fmt.Errorf("cache: read snapshot %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// capture 复制没有超过宽限期的数据，rotate 不为空时在持有读锁期间调用，
+// 保证快照和轮转后的追加日志之间没有遗漏的写入
+func (c *LocalCache[K, V]) capture(rotate func()) []snapshotEntry[K, V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := c.appendEntries(nil, c.clock.Now().UnixNano())
+	if rotate != nil {
+		rotate()
+	}
+	return entries
+}
+
+// appendEntries 调用方持有读锁
+func (c *LocalCache[K, V]) appendEntries(dst []snapshotEntry[K, V], now int64) []snapshotEntry[K, V] {
+	for key, it := range c.data {
+		if !c.dead(it, now) {
+			dst = append(dst, snapshotEntry[K, V]{key, it})
+		}
+	}
+	return dst
+}
+
+// restoreEntry 写入快照或日志中的数据，保留原来的过期时间，已经超过宽限期的数据被丢弃
+func (c *LocalCache[K, V]) restoreEntry(key K, it item[V]) {
+	if c.dead(it, c.clock.Now().UnixNano()) {
+		return
+	}
+	if !it.negative {
+		it.size = c.sizeOf(key, it.value)
+	} else if c.negatives == nil {
+		return
+	}
+
+	c.mu.Lock()
+	evicted := c.store(key, it)
+	c.queueEvicted(evicted, EvictCapacity)
+	c.mu.Unlock()
+
+	c.events.flush()
+	c.notifyEvicted(evicted, EvictCapacity)
+}
+
+// WriteSnapshot 把缓存数据连同剩余的过期时间写入 w，使用 Config.Persist 中的编码
+func (c *LocalCache[K, V]) WriteSnapshot(w io.Writer) error {
+	keys, values := persistCodecs(c.cfg.Persist)
+	entries := c.capture(nil)
+	return writeSnapshot(w, c.clock.Now(), entries, keys, values)
+}
+
+// ReadSnapshot 校验并恢复 WriteSnapshot 写入的数据，返回快照中的数据条数。
+// 快照损坏时不恢复任何数据，已经过期的数据不会恢复
+func (c *LocalCache[K, V]) ReadSnapshot(r io.Reader) (int, error) {
+	keys, values := persistCodecs(c.cfg.Persist)
+	entries, err := readSnapshot(r, keys, values)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		c.restoreEntry(e.key, e.it)
+	}
+	return len(entries), nil
+}
+
+// capture 同时持有所有分片的读锁，得到一致的快照
+func (c *ShardedCache[K, V]) capture(rotate func()) []snapshotEntry[K, V] {
+	for _, shard := range c.shards {
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+
+	now := c.shards[0].clock.Now().UnixNano()
+	var entries []snapshotEntry[K, V]
+	for _, shard := range c.shards {
+		entries = shard.appendEntries(entries, now)
+	}
+	if rotate != nil {
+		rotate()
+	}
+	return entries
+}
+
+func (c *ShardedCache[K, V]) restoreEntry(key K, it item[V]) {
+	c.shard(key).restoreEntry(key, it)
+}
+
+// WriteSnapshot 把所有分片的数据写入同一个快照，恢复时按 key 重新分片，分片数可以不同
+func (c *ShardedCache[K, V]) WriteSnapshot(w io.Writer) error {
+	keys, values := persistCodecs(c.cfg.Persist)
+	entries := c.capture(nil)
+	return writeSnapshot(w, c.shards[0].clock.Now(), entries, keys, values)
+}
+
+// ReadSnapshot 和 LocalCache.ReadSnapshot 相同
+func (c *ShardedCache[K, V]) ReadSnapshot(r io.Reader) (int, error) {
+	keys, values := persistCodecs(c.cfg.Persist)
+	entries, err := readSnapshot(r, keys, values)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		c.restoreEntry(e.key, e.it)
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 加载耗时直方图的桶上界，单位秒，和 Prometheus 客户端的默认桶一致
+var latencyBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Stats 是缓存的统计信息，计数从缓存创建开始累计
+type Stats struct {
+	// Hits 是命中没有过期数据的次数
+	Hits uint64
+	// StaleHits 是开启 StaleTTL 后返回旧值的次数
+	StaleHits uint64
+	// NegativeHits 是命中负缓存的次数
+	NegativeHits uint64
+	// Misses 是未命中的次数
+	Misses uint64
+	// FilterRejects 是过滤器判断 key 不存在、没有访问数据源的次数
+	FilterRejects uint64
+
+	// Loads 是访问数据源的次数，返回 ErrNotFound 也算作成功
+	Loads uint64
+	// LoadErrors 是访问数据源失败的次数
+	LoadErrors uint64
+	// LoadLatency 是访问数据源的耗时分布
+	LoadLatency Histogram
+	// InflightLoads 是正在访问数据源的加载数，只在开启限流或熔断时统计
+	InflightLoads int64
+
+	// RateLimited 是超过 LoadRate 被拒绝的加载次数
+	RateLimited uint64
+	// CircuitRejected 是熔断器打开时被拒绝的加载次数
+	CircuitRejected uint64
+	// BreakerOpens 是熔断器打开的次数
+	BreakerOpens uint64
+	// Breaker 是熔断器当前的状态
+	Breaker BreakerState
+
+	// PendingWrites 是 write-behind 还没有写入后端存储的 key 数
+	PendingWrites int
+	// FlushedWrites 是 write-behind 写入后端存储的修改条数
+	FlushedWrites uint64
+	// FailedWrites 是重试后仍然写入失败、重新排队的修改条数
+	FailedWrites uint64
+
+	// Evictions 是因为容量被淘汰的条数
+	Evictions uint64
+	// Expirations 是过期后被惰性删除或者后台清理的条数
+	Expirations uint64
+
+	// Size 是当前的数据条数，包括负缓存和还没有被清理的过期数据
+	Size int
+	// Bytes 是当前数据估算的字节数，只在设置了 MaxBytes 时统计
+	Bytes int64
+}
+
+// Histogram 是累计直方图，Counts[i] 是耗时不超过 Buckets[i] 秒的次数
+type Histogram struct {
+	Buckets []float64
+	Counts  []uint64
+	// Sum 是所有耗时之和，单位秒
+	Sum float64
+	// Count 是总次数
+	Count uint64
+}
+
+// merge 合并另一个桶相同的直方图
+func (h *Histogram) merge(o Histogram) {
+	if h.Buckets == nil {
+		h.Buckets = o.Buckets
+		h.Counts = make([]uint64, len(o.Counts))
+	}
+	for i := range o.Counts {
+		h.Counts[i] += o.Counts[i]
+	}
+	h.Sum += o.Sum
+	h.Count += o.Count
+}
+
+// StatsProvider 是可以导出统计信息的缓存
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// counters 使用原子操作累计统计信息，不需要持有缓存的锁
+type counters struct {
+	hits, staleHits, negativeHits, misses, filterRejects atomic.Uint64
+	loads, loadErrors                                    atomic.Uint64
+	evictions, expirations                               atomic.Uint64
+
+	// 每个桶单独计数，读取时再累加成累计直方图
+	latency    [len(latencyBuckets)]atomic.Uint64
+	latencyInf atomic.Uint64
+	latencySum atomic.Int64
+}
+
+func (s *counters) observeLoad(d time.Duration, err error) {
+	s.loads.Add(1)
+	if err != nil {
+		s.loadErrors.Add(1)
+	}
+
+	s.latencySum.Add(int64(d))
+	seconds := d.Seconds()
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			s.latency[i].Add(1)
+			return
+		}
+	}
+	s.latencyInf.Add(1)
+}
+
+func (s *counters) histogram() Histogram {
+	h := Histogram{
+		Buckets: latencyBuckets[:],
+		Counts:  make([]uint64, len(latencyBuckets)),
+		Sum:     time.Duration(s.latencySum.Load()).Seconds(),
+	}
+
+	var cumulative uint64
+	for i := range latencyBuckets {
+		cumulative += s.latency[i].Load()
+		h.Counts[i] = cumulative
+	}
+	h.Count = cumulative + s.latencyInf.Load()
+	return h
+}
+
+// Stats 返回缓存的统计信息
+func (c *LocalCache[K, V]) Stats() Stats {
+	c.mu.RLock()
+	size, bytes := len(c.data), c.bytes
+	c.mu.RUnlock()
+
+	stats := Stats{
+		Hits:          c.counters.hits.Load(),
+		StaleHits:     c.counters.staleHits.Load(),
+		NegativeHits:  c.counters.negativeHits.Load(),
+		Misses:        c.counters.misses.Load(),
+		FilterRejects: c.counters.filterRejects.Load(),
+		Loads:         c.counters.loads.Load(),
+		LoadErrors:    c.counters.loadErrors.Load(),
+		LoadLatency:   c.counters.histogram(),
+		Evictions:     c.counters.evictions.Load(),
+		Expirations:   c.counters.expirations.Load(),
+		Size:          size,
+		Bytes:         bytes,
+	}
+	c.guard.stats(&stats)
+	c.writer.stats(&stats)
+	return stats
+}
+
+// Stats 返回所有分片统计信息的汇总
+func (c *ShardedCache[K, V]) Stats() Stats {
+	var stats Stats
+	for _, shard := range c.shards {
+		s := shard.Stats()
+		stats.Hits += s.Hits
+		stats.StaleHits += s.StaleHits
+		stats.NegativeHits += s.NegativeHits
+		stats.Misses += s.Misses
+		stats.FilterRejects += s.FilterRejects
+		stats.Loads += s.Loads
+		stats.LoadErrors += s.LoadErrors
+		stats.LoadLatency.merge(s.LoadLatency)
+		stats.Evictions += s.Evictions
+		stats.Expirations += s.Expirations
+		stats.Size += s.Size
+		stats.Bytes += s.Bytes
+	}
+	// 所有分片共用同一个 guard 和写入队列
+	c.shards[0].guard.stats(&stats)
+	c.shards[0].writer.stats(&stats)
+	return stats
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordStats 在容量为 2 的缓存上制造命中、未命中、加载、加载失败、淘汰和过期
+func recordStats(t *testing.T) *LocalCache[string, int] {
+	t.Helper()
+	clock := NewFakeClock(epoch)
+	cache := NewLocalCache(Config[string, int]{Clock: clock, MaxEntries: 2})
+	t.Cleanup(func() { cache.Close() })
+	ctx := context.Background()
+
+	cache.Get("a")
+	if _, err := cache.GetOrLoad(ctx, "a", time.Hour, func(ctx context.Context, key string) (int, error) {
+		clock.Add(30 * time.Millisecond)
+		return 1, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cache.Get("a")
+	cache.GetOrLoad(ctx, "a", time.Hour, nil)
+	if _, err := cache.GetOrLoad(ctx, "b", time.Hour, func(ctx context.Context, key string) (int, error) {
+		clock.Add(2 * time.Second)
+		return 0, errors.New("database down")
+	}); err == nil {
+		t.Fatal("GetOrLoad hid the load error")
+	}
+
+	cache.Set("b", 2, time.Hour)
+	cache.Set("c", 3, time.Second) // 淘汰 a
+	clock.Add(2 * time.Second)
+	cache.Get("c") // 惰性删除过期的 c
+	return cache
+}
+
+func TestStats(t *testing.T) {
+	stats := recordStats(t).Stats()
+
+	counts := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"Hits", stats.Hits, 2},
+		{"Misses", stats.Misses, 4},
+		{"Loads", stats.Loads, 2},
+		{"LoadErrors", stats.LoadErrors, 1},
+		{"Evictions", stats.Evictions, 1},
+		{"Expirations", stats.Expirations, 1},
+	}
+	for _, c := range counts {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if stats.Size != 1 {
+		t.Errorf("Size = %d, want 1", stats.Size)
+	}
+
+	// 30ms 落在 0.05 的桶，2s 落在 2.5 的桶，桶的计数是累计的
+	h := stats.LoadLatency
+	for i, le := range h.Buckets {
+		want := uint64(0)
+		switch {
+		case le >= 2.5:
+			want = 2
+		case le >= 0.05:
+			want = 1
+		}
+		if h.Counts[i] != want {
+			t.Errorf("bucket le=%v = %d, want %d", le, h.Counts[i], want)
+		}
+	}
+	if h.Count != 2 || h.Sum != 2.03 {
+		t.Errorf("histogram count = %d, sum = %v, want 2 and 2.03", h.Count, h.Sum)
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	cache := NewShardedCache(4, Config[int, int]{}, nil)
+	defer cache.Close()
+
+	load := func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}
+	for i := 0; i < 100; i++ {
+		cache.GetOrLoad(context.Background(), i, time.Hour, load)
+		cache.Get(i)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 100 || stats.Misses != 100 || stats.Loads != 100 || stats.Size != 100 {
+		t.Fatalf("Stats = %+v, want 100 hits, misses, loads and entries", stats)
+	}
+	if stats.LoadLatency.Count != 100 || len(stats.LoadLatency.Counts) != len(latencyBuckets) {
+		t.Fatalf("LoadLatency = %+v, want 100 loads in %d buckets", stats.LoadLatency, len(latencyBuckets))
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	caches := map[string]StatsProvider{`users "v2"`: recordStats(t)}
+	filters := map[string]FPREstimator{"keys": NewBloomFilter(100, 0.01)}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(caches, filters).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE cache_hits_total counter",
+		`cache_hits_total{cache="users \"v2\""} 2`,
+		`cache_misses_total{cache="users \"v2\""} 4`,
+		`cache_loads_total{cache="users \"v2\""} 2`,
+		`cache_load_errors_total{cache="users \"v2\""} 1`,
+		`cache_evictions_total{cache="users \"v2\""} 1`,
+		`cache_expirations_total{cache="users \"v2\""} 1`,
+		"# TYPE cache_entries gauge",
+		`cache_entries{cache="users \"v2\""} 1`,
+		"# TYPE cache_load_duration_seconds histogram",
+		`cache_load_duration_seconds_bucket{cache="users \"v2\"",le="0.025"} 0`,
+		`cache_load_duration_seconds_bucket{cache="users \"v2\"",le="0.05"} 1`,
+		`cache_load_duration_seconds_bucket{cache="users \"v2\"",le="+Inf"} 2`,
+		`cache_load_duration_seconds_sum{cache="users \"v2\""} 2.03`,
+		`cache_load_duration_seconds_count{cache="users \"v2\""} 2`,
+		`cache_filter_estimated_false_positive_rate{filter="keys"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
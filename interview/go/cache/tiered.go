@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultL1TTL     = 10 * time.Second
+	defaultL2Timeout = time.Second
+)
+
+// TieredConfig 配置两级缓存
+type TieredConfig[K comparable, V any] struct {
+	// L1 是进程内的一级缓存，为空时使用开启 BreakdownLock 的 LocalCache
+	L1 Cache[K, V]
+
+	// L2 是多个副本共享的二级缓存，通常是 NewRESPClient 连接的 Redis
+	L2 RemoteStore
+
+	// Codec 把值编码后写入 L2，默认使用 DefaultCodec
+	Codec Codec[V]
+
+	// Prefix 加在 L2 的 key 前面，区分共用同一个 L2 的不同缓存
+	Prefix string
+
+	// L1TTL 限制数据在 L1 中的存活时间，其他副本更新 L2 后，
+	// 本副本最多在 L1TTL 内读到旧值，默认 10 秒
+	L1TTL time.Duration
+
+	// L2Timeout 限制每次访问 L2 和获取分布式锁的时间，默认 1 秒。GetOrLoad 合并后的加载
+	// 不会随调用方的 ctx 取消，L2 没有响应时也会在 L2Timeout 后放弃
+	L2Timeout time.Duration
+
+	// Bus 不为空时，Set 和 Delete 写入 L2 后发布失效消息，收到其他实例的消息后删除 L1 中的 key，
+	// 使用完需要调用 Close
+	Bus *InvalidationBus
+
+	// RefreshOnInvalidate 为 true 时，收到失效消息后在后台从 L2 读取新值，而不是等下次访问
+	RefreshOnInvalidate bool
+
+	// Locker 不为空时，L2 未命中后先获得 key 的锁再访问数据源，获得锁后重新读取 L2，
+	// 让多个副本中同一个 key 只加载一次。加载函数可以通过 LeaseFromContext 取得 fencing token
+	Locker Locker
+
+	// Clock 是默认创建的 L1 使用的时钟，传入 L1 时在 L1 的 Config 中设置
+	Clock Clock
+
+	// Logger 记录 L2 的访问和错误，为空时不输出
+	Logger *slog.Logger
+}
+
+// TieredCache 在共享的 L2 前面加一层进程内的 L1：
+// 读取依次查 L1、L2 和数据源并逐级回填，写入和删除同时作用于两级缓存。
+// L2 不可用时退化为只使用 L1 和数据源
+type TieredCache[K comparable, V any] struct {
+	cfg TieredConfig[K, V]
+	l1  Cache[K, V]
+	log *slog.Logger
+
+	unsubscribe func()
+}
+
+var _ Cache[string, string] = (*TieredCache[string, string])(nil)
+
+// 创建两级缓存
+func NewTieredCache[K comparable, V any](cfg TieredConfig[K, V]) *TieredCache[K, V] {
+	if cfg.L1TTL <= 0 {
+		cfg.L1TTL = defaultL1TTL
+	}
+	if cfg.L2Timeout <= 0 {
+		cfg.L2Timeout = defaultL2Timeout
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = DefaultCodec[V]()
+	}
+
+	c := &TieredCache[K, V]{
+		cfg: cfg,
+		l1:  cfg.L1,
+		log: cfg.Logger,
+	}
+	if c.log == nil {
+		c.log = discard
+	}
+	if c.l1 == nil {
+		c.l1 = NewLocalCache(Config[K, V]{BreakdownLock: true, Clock: cfg.Clock, Logger: cfg.Logger})
+	}
+	if cfg.Bus != nil {
+		c.unsubscribe = SubscribeInvalidations[K, V](cfg.Bus, c, cfg.Prefix, cfg.RefreshOnInvalidate)
+	}
+	return c
+}
+
+// 获取缓存数据，L1 未命中时读取 L2 并回填 L1
+func (c *TieredCache[K, V]) Get(key K) (V, bool) {
+	if value, found := c.l1.Get(key); found {
+		return value, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.L2Timeout)
+	defer cancel()
+
+	value, remaining, err := c.getRemote(ctx, key)
+	if err != nil {
+		var zero V
+		return zero, false
+	}
+	c.l1.Set(key, value, c.l1TTL(remaining))
+	return value, true
+}
+
+// 同时写入 L2 和 L1，L2 写入失败时只写入 L1
+func (c *TieredCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.L2Timeout)
+	defer cancel()
+
+	c.setRemote(ctx, key, value, ttl)
+	c.l1.Set(key, value, c.l1TTL(ttl))
+	c.publish(ctx, key)
+}
+
+// 同时删除 L2 和 L1 中的数据
+func (c *TieredCache[K, V]) Delete(key K) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.L2Timeout)
+	defer cancel()
+
+	if err := c.cfg.L2.Delete(ctx, c.remoteKey(key)); err != nil {
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache l2 delete failed", slog.Any("key", key), slog.Any("error", err))
+	}
+	c.l1.Delete(key)
+	c.publish(ctx, key)
+}
+
+// Invalidate 只删除 L1 中的数据，用于其他副本更新了 L2 之后让本副本重新读取 L2
+func (c *TieredCache[K, V]) Invalidate(key K) {
+	if inv, ok := c.l1.(interface{ Invalidate(key K) }); ok {
+		inv.Invalidate(key)
+		return
+	}
+	c.l1.Delete(key)
+}
+
+// 获取缓存数据，L1 未命中时读取 L2，L2 也未命中时通过 load 加载并写入两级缓存。
+// 同一个 key 的并发加载由 L1 合并，L2 出错时直接访问数据源
+func (c *TieredCache[K, V]) GetOrLoad(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error) {
+	return c.l1.GetOrLoad(ctx, key, c.l1TTL(ttl), func(ctx context.Context, key K) (V, error) {
+		value, remaining, err := c.getRemote(ctx, key)
+		if err == nil {
+			limitLoadTTL(ctx, c.l1TTL(remaining))
+			return value, nil
+		}
+		if c.cfg.Locker != nil {
+			return c.loadLocked(ctx, key, ttl, load)
+		}
+
+		value, err = load(ctx, key)
+		if err != nil {
+			return value, err
+		}
+		c.setRemote(ctx, key, value, ttl)
+		return value, nil
+	})
+}
+
+// loadLocked 持有分布式锁加载数据，锁服务不可用时直接访问数据源
+func (c *TieredCache[K, V]) loadLocked(ctx context.Context, key K, ttl time.Duration, load Loader[K, V]) (V, error) {
+	lockCtx, cancel := context.WithTimeout(ctx, c.cfg.L2Timeout)
+	lease, err := c.cfg.Locker.Lock(lockCtx, c.remoteKey(key))
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			var zero V
+			return zero, ctx.Err()
+		}
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache lock failed", slog.Any("key", key), slog.Any("error", err))
+		value, err := load(ctx, key)
+		if err == nil {
+			c.setRemote(ctx, key, value, ttl)
+		}
+		return value, err
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.cfg.L2Timeout)
+		defer cancel()
+		if err := lease.Unlock(unlockCtx); err != nil {
+			c.log.LogAttrs(ctx, slog.LevelWarn, "cache unlock failed", slog.Any("key", key), slog.Any("error", err))
+		}
+	}()
+
+	// 等待锁期间其他副本可能已经加载完成
+	if value, remaining, err := c.getRemote(ctx, key); err == nil {
+		limitLoadTTL(ctx, c.l1TTL(remaining))
+		return value, nil
+	}
+
+	value, err := load(WithLease(ctx, lease), key)
+	if err != nil {
+		return value, err
+	}
+
+	select {
+	case <-lease.Done():
+		// 锁已经被其他副本拿走，它会写入更新的值
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache lease lost before l2 set", slog.Any("key", key),
+			slog.Uint64("token", lease.Token()))
+	default:
+		c.setRemote(ctx, key, value, ttl)
+	}
+	return value, nil
+}
+
+// getRemote 读取 L2，同时返回 key 在 L2 中剩余的有效期
+func (c *TieredCache[K, V]) getRemote(ctx context.Context, key K) (V, time.Duration, error) {
+	var zero V
+
+	l2Ctx, cancel := context.WithTimeout(ctx, c.cfg.L2Timeout)
+	data, remaining, err := c.cfg.L2.Get(l2Ctx, c.remoteKey(key))
+	cancel()
+	if errors.Is(err, ErrNotFound) {
+		c.debug(ctx, "cache l2 miss", key)
+		return zero, 0, err
+	}
+	if err != nil {
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache l2 get failed", slog.Any("key", key), slog.Any("error", err))
+		return zero, 0, err
+	}
+
+	value, err := c.cfg.Codec.Decode(data)
+	if err != nil {
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache l2 decode failed", slog.Any("key", key), slog.Any("error", err))
+		return zero, 0, err
+	}
+	c.debug(ctx, "cache l2 hit", key)
+	return value, remaining, nil
+}
+
+func (c *TieredCache[K, V]) setRemote(ctx context.Context, key K, value V, ttl time.Duration) {
+	data, err := c.cfg.Codec.Encode(value)
+	if err == nil {
+		l2Ctx, cancel := context.WithTimeout(ctx, c.cfg.L2Timeout)
+		err = c.cfg.L2.Set(l2Ctx, c.remoteKey(key), data, ttl)
+		cancel()
+	}
+	if err != nil {
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache l2 set failed", slog.Any("key", key), slog.Any("error", err))
+	}
+}
+
+// 通知其他实例删除 L1 中的 key，发布失败时其他实例最多在 L1TTL 内读到旧值
+func (c *TieredCache[K, V]) publish(ctx context.Context, key K) {
+	if c.cfg.Bus == nil {
+		return
+	}
+	if err := c.cfg.Bus.Publish(ctx, c.remoteKey(key)); err != nil {
+		c.log.LogAttrs(ctx, slog.LevelWarn, "cache invalidation publish failed", slog.Any("key", key), slog.Any("error", err))
+	}
+}
+
+// Close 取消订阅失效消息，不关闭 L1、L2 和 Bus
+func (c *TieredCache[K, V]) Close() {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+	}
+}
+
+func (c *TieredCache[K, V]) remoteKey(key K) string {
+	return c.cfg.Prefix + keyString(key)
+}
+
+// L1 中的数据不能比 L2 活得更久，ttl 小于等于 0 表示在 L2 中没有过期时间
+func (c *TieredCache[K, V]) l1TTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return c.cfg.L1TTL
+	}
+	return min(ttl, c.cfg.L1TTL)
+}
+
+func (c *TieredCache[K, V]) debug(ctx context.Context, msg string, key K) {
+	if c.log.Enabled(ctx, slog.LevelDebug) {
+		c.log.LogAttrs(ctx, slog.LevelDebug, msg, slog.Any("key", key))
+	}
+}
@@ -0,0 +1,54 @@
+// The demo is for testing TTLs with a fake clock
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// 模拟用 FakeClock 推进时间：不需要 sleep 就能观察过期、stale-while-revalidate 和后台清理
+func SimulateFakeClock() {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewLocalCache(Config[string, string]{
+		StaleTTL:        5 * time.Second,
+		JanitorInterval: time.Second,
+		Clock:           clock,
+		Logger:          logger,
+	})
+	defer cache.Close()
+
+	// 后台刷新和后台清理在其他 goroutine 中执行，通过事件等待它们完成后再推进时间
+	events := cache.Subscribe(SubscribeOptions{Types: []EventType{EventSet, EventExpire}})
+	defer events.Close()
+	waitFor := func(typ EventType, key string) {
+		for e := range events.C {
+			if e.Type == typ && e.Key == key {
+				return
+			}
+		}
+	}
+
+	queryFromDB := func(ctx context.Context, key string) (string, error) {
+		return "Data at " + clock.Now().Format(time.TimeOnly), nil
+	}
+
+	cache.GetOrLoad(context.Background(), "hotkey", 10*time.Second, queryFromDB)
+	waitFor(EventSet, "hotkey")
+	for _, step := range []time.Duration{9 * time.Second, 2 * time.Second, 10 * time.Second} {
+		clock.Add(step)
+		_, found := cache.Get("hotkey")
+		res, err := cache.Fetch(context.Background(), "hotkey", 10*time.Second, queryFromDB)
+		logger.Info("advanced", "now", clock.Now().Format(time.TimeOnly), "fresh", found,
+			"value", res.Value, "stale", res.Stale, "error", err)
+		if res.Stale {
+			waitFor(EventSet, "hotkey")
+		}
+	}
+
+	// 写入后跳过 TTL 和宽限期，等待后台清理删除它
+	cache.Set("coldkey", "Cold Data", time.Second)
+	clock.Add(10 * time.Second)
+	waitFor(EventExpire, "coldkey")
+	logger.Info("janitor", "stats", cache.JanitorStats(), "entries", cache.Len())
+}
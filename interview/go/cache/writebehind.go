@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWriteBatch    = 100
+	defaultWriteInterval = time.Second
+	defaultWriteRetries  = 3
+	defaultWriteBackoff  = 100 * time.Millisecond
+)
+
+// Write 是 write-behind 写入后端存储的一条修改，Delete 为 true 时删除 Key
+type Write[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Delete bool
+}
+
+// Store 是 write-behind 的后端存储，WriteBatch 失败时整批重试，实现需要保证重复写入是幂等的
+type Store[K comparable, V any] interface {
+	WriteBatch(ctx context.Context, writes []Write[K, V]) error
+}
+
+// WriteBehindConfig 配置 write-behind，Store 为空时不开启。
+// 开启后 Set 和 Delete 先修改内存，再异步批量写入 Store，同一个 key 还没写入的修改会合并成最后一次。
+// Close 之后的 Set 和 Delete 只修改内存，不会写入 Store，计入 FailedWrites
+type WriteBehindConfig[K comparable, V any] struct {
+	Store Store[K, V]
+
+	// BatchSize 是每批写入的最大条数，待写入的 key 达到 BatchSize 时立即写入，默认 100
+	BatchSize int
+
+	// Interval 是定期写入的间隔，默认 1 秒
+	Interval time.Duration
+
+	// MaxRetries 是每批写入失败后的重试次数，默认 3 次。重试都失败的修改放回队列，下一轮再写
+	MaxRetries int
+
+	// RetryBackoff 是第一次重试前的等待时间，之后每次加倍，默认 100 毫秒
+	RetryBackoff time.Duration
+
+	// Timeout 大于 0 时限制每次 WriteBatch 的时间
+	Timeout time.Duration
+}
+
+// writeBehind 合并待写入的修改并在后台批量写入，方法可以在 nil 上调用
+type writeBehind[K comparable, V any] struct {
+	cfg WriteBehindConfig[K, V]
+	log *slog.Logger
+
+	mu sync.Mutex
+	// pending 按 key 合并修改，order 记录 key 第一次变脏的顺序
+	pending map[K]Write[K, V]
+	order   []K
+	// closed 之后不再接受新的修改
+	closed bool
+	// 保证同一时间只有一轮写入，避免同一个 key 的新旧修改乱序到达 Store
+	flushMu sync.Mutex
+
+	flushed, failed atomic.Uint64
+
+	clock     Clock
+	ticker    Ticker
+	kick      chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newWriteBehind[K comparable, V any](cfg WriteBehindConfig[K, V], clock Clock, log *slog.Logger) *writeBehind[K, V] {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWriteBatch
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultWriteInterval
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultWriteRetries
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultWriteBackoff
+	}
+	if log == nil {
+		log = discard
+	}
+
+	w := &writeBehind[K, V]{
+		cfg:     cfg,
+		log:     log,
+		pending: make(map[K]Write[K, V]),
+		clock:   clock,
+		ticker:  clock.NewTicker(cfg.Interval),
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *writeBehind[K, V]) set(key K, value V) {
+	w.enqueue(Write[K, V]{Key: key, Value: value})
+}
+
+func (w *writeBehind[K, V]) delete(key K) {
+	w.enqueue(Write[K, V]{Key: key, Delete: true})
+}
+
+func (w *writeBehind[K, V]) enqueue(write Write[K, V]) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		w.failed.Add(1)
+		w.log.Warn("cache write-behind closed, write dropped", "key", write.Key, "delete", write.Delete)
+		return
+	}
+	if _, ok := w.pending[write.Key]; !ok {
+		w.order = append(w.order, write.Key)
+	}
+	w.pending[write.Key] = write
+	full := len(w.order) >= w.cfg.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *writeBehind[K, V]) pendingLen() int {
+	if w == nil {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.order)
+}
+
+func (w *writeBehind[K, V]) run() {
+	defer close(w.stopped)
+
+	defer w.ticker.Stop()
+
+	for {
+		select {
+		case <-w.ticker.Chan():
+		case <-w.kick:
+		case <-w.stop:
+			return
+		}
+		w.flush(w.stop)
+	}
+}
+
+// flush 写入当前所有待写入的修改，返回没有写入成功的条数。
+// stop 关闭时不再等待重试，Close 时传入 nil 等待所有重试
+func (w *writeBehind[K, V]) flush(stop <-chan struct{}) int {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	writes := make([]Write[K, V], 0, len(w.order))
+	for _, key := range w.order {
+		writes = append(writes, w.pending[key])
+	}
+	w.pending = make(map[K]Write[K, V])
+	w.order = nil
+	w.mu.Unlock()
+
+	var lost []Write[K, V]
+	for start := 0; start < len(writes); start += w.cfg.BatchSize {
+		batch := writes[start:min(start+w.cfg.BatchSize, len(writes))]
+		if err := w.write(batch, stop); err != nil {
+			lost = append(lost, batch...)
+			if stopping(stop) {
+				// 重试被 Close 打断，Close 会再写一次
+				continue
+			}
+			w.failed.Add(uint64(len(batch)))
+			w.log.Warn("cache write-behind failed", "writes", len(batch), "error", err)
+			continue
+		}
+		w.flushed.Add(uint64(len(batch)))
+	}
+
+	w.requeue(lost)
+	return len(lost)
+}
+
+// write 写入一批修改，失败后按指数退避重试
+func (w *writeBehind[K, V]) write(batch []Write[K, V], stop <-chan struct{}) error {
+	backoff := w.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.Background(), func() {}
+		if w.cfg.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, w.cfg.Timeout)
+		}
+		err := w.cfg.Store.WriteBatch(ctx, batch)
+		cancel()
+		if err == nil || attempt == w.cfg.MaxRetries {
+			return err
+		}
+
+		w.log.Debug("cache write-behind retry", "writes", len(batch), "attempt", attempt+1, "error", err)
+		if !sleep(w.clock, backoff, stop) {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func stopping(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// requeue 把写入失败的修改放回队列，期间有新修改的 key 以新修改为准
+func (w *writeBehind[K, V]) requeue(writes []Write[K, V]) {
+	if len(writes) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var order []K
+	for _, write := range writes {
+		if _, ok := w.pending[write.Key]; !ok {
+			w.pending[write.Key] = write
+			order = append(order, write.Key)
+		}
+	}
+	w.order = append(order, w.order...)
+}
+
+// close 停止后台写入并写完所有待写入的修改，可以重复调用
+func (w *writeBehind[K, V]) close() error {
+	if w == nil {
+		return nil
+	}
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.stopped
+
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		if lost := w.flush(nil); lost > 0 {
+			w.closeErr = fmt.Errorf("cache: write-behind dropped %d writes on close", lost)
+		}
+	})
+	return w.closeErr
+}
+
+func (w *writeBehind[K, V]) stats(s *Stats) {
+	if w == nil {
+		return
+	}
+	s.PendingWrites = w.pendingLen()
+	s.FlushedWrites = w.flushed.Load()
+	s.FailedWrites = w.failed.Load()
+}
+
+// Flush 立即把待写入的修改写入 Store，返回没有写入成功的条数，没有开启 write-behind 时返回 0
+func (c *LocalCache[K, V]) Flush() int {
+	if c.writer == nil {
+		return 0
+	}
+	return c.writer.flush(nil)
+}
+
+// Flush 和 LocalCache.Flush 相同，所有分片共用一个写入队列
+func (c *ShardedCache[K, V]) Flush() int {
+	return c.shards[0].Flush()
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore 是记录写入结果的 Store
+type memoryStore struct {
+	mu   sync.Mutex
+	rows map[int]int
+}
+
+func (s *memoryStore) WriteBatch(ctx context.Context, writes []Write[int, int]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range writes {
+		if w.Delete {
+			delete(s.rows, w.Key)
+		} else {
+			s.rows[w.Key] = w.Value
+		}
+	}
+	return nil
+}
+
+// 并发写入同一个 key 后，Store 中的值必须和内存中的一致
+func TestWriteBehindMatchesMemory(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		store := &memoryStore{rows: make(map[int]int)}
+		cache := NewLocalCache(Config[int, int]{WriteBehind: WriteBehindConfig[int, int]{Store: store, BatchSize: 8}})
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					if i%10 == 9 {
+						cache.Delete(i % 4)
+					} else {
+						cache.Set(i%4, g*1000+i, time.Hour)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		if err := cache.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for key := 0; key < 4; key++ {
+			value, found := cache.Get(key)
+			row, stored := store.rows[key]
+			if found != stored || value != row {
+				t.Fatalf("key %d: memory %d, %v, store %d, %v", key, value, found, row, stored)
+			}
+		}
+	}
+}
+
+func TestWriteBehindRejectsAfterClose(t *testing.T) {
+	store := &memoryStore{rows: make(map[int]int)}
+	cache := NewLocalCache(Config[int, int]{WriteBehind: WriteBehindConfig[int, int]{Store: store}})
+
+	cache.Set(1, 1, time.Hour)
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cache.Set(2, 2, time.Hour)
+
+	if _, stored := store.rows[2]; stored {
+		t.Fatal("write after Close reached the store")
+	}
+	stats := cache.Stats()
+	if stats.FlushedWrites != 1 || stats.FailedWrites != 1 || stats.PendingWrites != 0 {
+		t.Fatalf("Stats = flushed %d, failed %d, pending %d, want 1, 1, 0",
+			stats.FlushedWrites, stats.FailedWrites, stats.PendingWrites)
+	}
+}
+
+// flakyStore 前 failures 次写入失败
+type flakyStore struct {
+	memoryStore
+	failures atomic.Int32
+	attempts atomic.Int32
+}
+
+func (s *flakyStore) WriteBatch(ctx context.Context, writes []Write[int, int]) error {
+	s.attempts.Add(1)
+	if s.failures.Add(-1) >= 0 {
+		return errors.New("store unavailable")
+	}
+	return s.memoryStore.WriteBatch(ctx, writes)
+}
+
+// 重试的退避等待使用 Config.Clock，推进时钟之前不会重试
+func TestWriteBehindRetryUsesClock(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	store := &flakyStore{memoryStore: memoryStore{rows: make(map[int]int)}}
+	store.failures.Store(2)
+	cache := NewLocalCache(Config[int, int]{
+		Clock: clock,
+		WriteBehind: WriteBehindConfig[int, int]{
+			Store:        store,
+			BatchSize:    1,
+			RetryBackoff: time.Hour,
+		},
+	})
+	defer cache.Close()
+
+	cache.Set(1, 1, 24*time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for store.attempts.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := store.attempts.Load(); n != 1 {
+		t.Fatalf("attempts = %d before the clock moved, want 1", n)
+	}
+
+	// 第一次退避 1 小时，第二次 2 小时
+	for store.attempts.Load() < 3 && time.Now().Before(deadline) {
+		clock.Add(time.Hour)
+		time.Sleep(time.Millisecond)
+	}
+	if cache.Flush() != 0 {
+		t.Fatal("Flush left writes pending")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.rows[1] != 1 || store.attempts.Load() != 3 {
+		t.Fatalf("store rows %v after %d attempts, want 1=1 after 3", store.rows, store.attempts.Load())
+	}
+}
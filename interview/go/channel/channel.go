@@ -1,7 +1,6 @@
 package channel
 
 import (
-	"fmt"
 	"sync"
 )
 
@@ -12,7 +11,7 @@ func worker1(msgChan chan msg, wg *sync.WaitGroup) {
 	msgChan <- m
 
 	for m1 := range msgChan {
-		fmt.Println(m1.id, m1.number)
+		logger.Info("receive", "id", m1.id, "number", m1.number)
 		if m1.number == 100 {
 			close(msgChan)
 			break
@@ -28,7 +27,7 @@ func worker2(msgChan chan msg, wg *sync.WaitGroup) {
 
 	var m = msg{id: 1, number: 0}
 	for m0 := range msgChan {
-		fmt.Println(m0.id, m0.number)
+		logger.Info("receive", "id", m0.id, "number", m0.number)
 		if m0.number == 100 {
 			close(msgChan)
 			break
@@ -1,7 +1,6 @@
 package channel
 
 import (
-	"fmt"
 	"sync"
 	"time"
 )
@@ -15,11 +14,11 @@ func worker(id int, taskChan <-chan task, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for t := range taskChan {
 		if t.t == "event" {
-			fmt.Printf("worker %d receive the task %s from %d\n", id, t.t, t.id)
+			logger.Info("receive the task", "worker", id, "task", t.t, "from", t.id)
 		}
 
 		if t.t == "log" {
-			fmt.Printf("worker %d receive the task %s from %d\n", id, t.t, t.id)
+			logger.Info("receive the task", "worker", id, "task", t.t, "from", t.id)
 		}
 	}
 }
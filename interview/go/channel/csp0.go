@@ -1,7 +1,6 @@
 package channel
 
 import (
-	"fmt"
 	"sync"
 )
 
@@ -11,11 +10,11 @@ func worker0(id int, taskChan <-chan task, stopChan <-chan struct{}, wg *sync.Wa
 		select {
 		case t := <-taskChan:
 			if t.t == "event" {
-				fmt.Printf("worker %d receive the task %s from %d\n", id, t.t, t.id)
+				logger.Info("receive the task", "worker", id, "task", t.t, "from", t.id)
 			}
 
 			if t.t == "log" {
-				fmt.Printf("worker %d receive the task %s from %d\n", id, t.t, t.id)
+				logger.Info("receive the task", "worker", id, "task", t.t, "from", t.id)
 			}
 		case <-stopChan:
 			return
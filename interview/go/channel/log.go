@@ -0,0 +1,24 @@
+package channel
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler 丢弃所有日志
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var logger = slog.New(discardHandler{})
+
+// SetLogger 设置 worker 使用的日志，nil 表示不输出
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(discardHandler{})
+	}
+	logger = l
+}